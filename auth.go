@@ -1,10 +1,47 @@
 package httpc
 
-import "net/http"
+import (
+	"context"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+)
 
-// AuthFn adds authorization to an http request.
+// ErrBodyNotReplayable is returned when the request body must be read, i.e. to
+// sign or resend it, but can not be read without consuming it.
+var ErrBodyNotReplayable = errors.New("request body is not replayable")
+
+// Authorizer adds authorization to an http request. Authorize is called for
+// every attempt made by a request, after the headers and query params are set.
+// When Authorize fails the request is not sent, and the attempt fails with an
+// HTTPErr wrapping the error. The HTTPErr is retryable when the error returned
+// provides a Retry() bool that is true.
+type Authorizer interface {
+	Authorize(ctx context.Context, r *http.Request) (*http.Request, error)
+}
+
+// AuthorizerFunc is an adapter to allow the use of ordinary functions as an
+// Authorizer.
+type AuthorizerFunc func(ctx context.Context, r *http.Request) (*http.Request, error)
+
+// Authorize calls fn(ctx, r).
+func (fn AuthorizerFunc) Authorize(ctx context.Context, r *http.Request) (*http.Request, error) {
+	return fn(ctx, r)
+}
+
+// AuthFn adds authorization to an http request. An AuthFn can not fail, use an
+// Authorizer when obtaining credentials may fail.
 type AuthFn func(*http.Request) *http.Request
 
+// Authorize implements Authorizer for AuthFn.
+func (fn AuthFn) Authorize(ctx context.Context, r *http.Request) (*http.Request, error) {
+	if fn == nil {
+		return r, nil
+	}
+	return fn(r), nil
+}
+
 // BasicAuth sets the basic authFn on the request.
 func BasicAuth(user, pass string) AuthFn {
 	return func(r *http.Request) *http.Request {
@@ -20,3 +57,25 @@ func BearerTokenAuth(token string) AuthFn {
 		return r
 	}
 }
+
+// bodyDigest hashes the request body without consuming it.
+func bodyDigest(r *http.Request, newHash func() hash.Hash) ([]byte, error) {
+	h := newHash()
+	if r.Body == nil || r.Body == http.NoBody {
+		return h.Sum(nil), nil
+	}
+	if r.GetBody == nil {
+		return nil, ErrBodyNotReplayable
+	}
+
+	body, err := r.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	if _, err := io.Copy(h, body); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
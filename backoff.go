@@ -23,14 +23,39 @@ type (
 		Next(retry int) (time.Duration, bool)
 	}
 
+	// RetryNotifyFn is called before the retry loop waits to retry a failed
+	// attempt, with the number of the retry about to be made, starting at 1,
+	// the error of the failed attempt and the wait before the retry.
+	RetryNotifyFn func(ctx context.Context, attempt int, err error, wait time.Duration)
+
+	// GiveUpFn is called when the retry loop stops retrying a retriable error,
+	// with the number of attempts made and the error returned.
+	GiveUpFn func(ctx context.Context, attempts int, err error)
+
 	backoffKey int
 )
 
 const backoffNumKey backoffKey = -33333
 
-// RetryNotify calls notify function with the error and wait duration
-// for each failed attempt before sleep.
-func retry(ctx context.Context, fn func(context.Context) error, b BackoffOptFn) error {
+// retryOpts are the policies applied by the retry loop.
+type retryOpts struct {
+	backoff BackoffOptFn
+	budget  *RetryBudget
+	clock   Clock
+	maxWait time.Duration
+
+	onRetry  []RetryNotifyFn
+	onGiveUp []GiveUpFn
+}
+
+// retry calls fn until it succeeds or returns an error that is not retriable,
+// waiting between attempts as directed by the backoff. When the error provides
+// a server directed delay, i.e. a Retry-After header, the wait is at least that
+// delay, capped at the max wait. Retrying stops early when the wait would
+// exceed the deadline of the context. The onRetry hooks are called with the error and
+// wait for each failed attempt before the wait, and the onGiveUp hooks when a
+// retriable error is no longer retried.
+func retry(ctx context.Context, fn func(context.Context) error, opts retryOpts) error {
 	type retrier interface {
 		Retry() bool
 	}
@@ -38,11 +63,18 @@ func retry(ctx context.Context, fn func(context.Context) error, b BackoffOptFn)
 	var err error
 	var n int
 
-	backoffPolicy := b()
+	type retryAfterer interface {
+		RetryAfter() (time.Duration, bool)
+	}
+
+	backoffPolicy := opts.backoff()
 	for {
 		ctx := context.WithValue(ctx, backoffNumKey, n)
 		err = fn(ctx)
 		if err == nil {
+			if opts.budget != nil {
+				opts.budget.deposit()
+			}
 			return nil
 		}
 		if r, ok := err.(retrier); ok && !r.Retry() {
@@ -52,17 +84,57 @@ func retry(ctx context.Context, fn func(context.Context) error, b BackoffOptFn)
 		n++
 		wait, retry := backoffPolicy.Next(n)
 		if !retry {
-			return err
+			return opts.giveUp(ctx, n, err)
+		}
+		if ra, ok := err.(retryAfterer); ok {
+			if d, ok := ra.RetryAfter(); ok && d > wait {
+				wait = d
+			}
+		}
+		if opts.maxWait > 0 && wait > opts.maxWait {
+			wait = opts.maxWait
+		}
+		// the context's deadline is in wall time, regardless of the clock
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return opts.giveUp(ctx, n, err)
+		}
+		if opts.budget != nil && !opts.budget.tryWithdraw() {
+			return opts.giveUp(ctx, n, &RetryBudgetErr{err: err})
 		}
 
+		for _, fn := range opts.onRetry {
+			fn(ctx, n, err, wait)
+		}
+
+		timer := opts.clock.NewTimer(wait)
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(wait):
+			timer.Stop()
+			return opts.giveUp(ctx, n, ctx.Err())
+		case <-timer.C():
 		}
 	}
 }
 
+func (o retryOpts) giveUp(ctx context.Context, attempts int, err error) error {
+	for _, fn := range o.onGiveUp {
+		fn(ctx, attempts, err)
+	}
+	return err
+}
+
+// BackoffMessage provides a condensed message of the error for logging during
+// a backoff loop, using the BackoffMessage of the error when provided.
+func BackoffMessage(err error) string {
+	type backoffMessager interface {
+		BackoffMessage() string
+	}
+	if b, ok := err.(backoffMessager); ok {
+		return b.BackoffMessage()
+	}
+	return err.Error()
+}
+
 // Attempt returns the backoff attempt that is currently in motion.
 func Attempt(ctx context.Context) (int, bool) {
 	attempNum, ok := ctx.Value(backoffNumKey).(int)
@@ -218,3 +290,144 @@ func jitter(millis int) int {
 	}
 	return millis/2 + rand.Intn(millis)
 }
+
+type jitterOpt struct {
+	rand       func() float64
+	now        func() time.Time
+	maxElapsed time.Duration
+}
+
+// JitterOptFn is a optional parameter for the jittered backoffs.
+type JitterOptFn func(o jitterOpt) jitterOpt
+
+// JitterRand sets the random source of a jittered backoff, returning a number
+// in [0.0,1.0). Defaults to rand.Float64.
+func JitterRand(fn func() float64) JitterOptFn {
+	return func(o jitterOpt) jitterOpt {
+		o.rand = fn
+		return o
+	}
+}
+
+// JitterNow sets the clock a jittered backoff measures the elapsed time with.
+// Defaults to time.Now.
+func JitterNow(fn func() time.Time) JitterOptFn {
+	return func(o jitterOpt) jitterOpt {
+		o.now = fn
+		return o
+	}
+}
+
+// JitterMaxElapsed stops the backoff once the time elapsed since the first
+// retry, plus the next wait, exceeds d. Defaults to no limit.
+func JitterMaxElapsed(d time.Duration) JitterOptFn {
+	return func(o jitterOpt) jitterOpt {
+		o.maxElapsed = d
+		return o
+	}
+}
+
+func newJitterOpt(opts []JitterOptFn) jitterOpt {
+	opt := jitterOpt{
+		rand: rand.Float64,
+		now:  time.Now,
+	}
+	for _, o := range opts {
+		opt = o(opt)
+	}
+	return opt
+}
+
+// expired reports whether waiting wait exceeds the max elapsed time since start.
+func (o jitterOpt) expired(start time.Time, wait time.Duration) bool {
+	return o.maxElapsed > 0 && o.now().Sub(start)+wait > o.maxElapsed
+}
+
+// FullJitterBackoff implements the "full jitter" backoff described at
+// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/.
+// The wait is random between 0 and the exponential backoff, capped at the
+// max: random(0, min(max, base * 2^(retry-1))).
+type FullJitterBackoff struct {
+	opt      jitterOpt
+	base     time.Duration
+	max      time.Duration
+	maxCalls int
+	start    time.Time
+}
+
+// NewFullJitterBackoff returns a FullJitterBackoff backoff policy. Use base
+// to set the ceiling of the first interval and max to set the maximum wait
+// interval.
+func NewFullJitterBackoff(base, max time.Duration, maxCalls int, opts ...JitterOptFn) BackoffOptFn {
+	return func() Backoffer {
+		return &FullJitterBackoff{
+			opt:      newJitterOpt(opts),
+			base:     base,
+			max:      max,
+			maxCalls: maxCalls,
+		}
+	}
+}
+
+// Next implements BackoffFunc for FullJitterBackoff.
+func (b *FullJitterBackoff) Next(retry int) (time.Duration, bool) {
+	if b.maxCalls > 0 && retry == b.maxCalls {
+		return 0, false
+	}
+	if b.start.IsZero() {
+		b.start = b.opt.now()
+	}
+
+	ceil := math.Min(float64(b.base)*math.Pow(2, float64(retry-1)), float64(b.max))
+	wait := time.Duration(b.opt.rand() * ceil)
+	if b.opt.expired(b.start, wait) {
+		return 0, false
+	}
+	return wait, true
+}
+
+// DecorrelatedJitterBackoff implements the "decorrelated jitter" backoff
+// described at https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/.
+// Each wait is random between the base and three times the previous wait,
+// capped at the max: min(max, random(base, prev * 3)).
+type DecorrelatedJitterBackoff struct {
+	opt      jitterOpt
+	base     time.Duration
+	max      time.Duration
+	maxCalls int
+	prev     time.Duration
+	start    time.Time
+}
+
+// NewDecorrelatedJitterBackoff returns a DecorrelatedJitterBackoff backoff
+// policy. Use base to set the minimal interval and max to set the maximum
+// wait interval.
+func NewDecorrelatedJitterBackoff(base, max time.Duration, maxCalls int, opts ...JitterOptFn) BackoffOptFn {
+	return func() Backoffer {
+		return &DecorrelatedJitterBackoff{
+			opt:      newJitterOpt(opts),
+			base:     base,
+			max:      max,
+			maxCalls: maxCalls,
+			prev:     base,
+		}
+	}
+}
+
+// Next implements BackoffFunc for DecorrelatedJitterBackoff.
+func (b *DecorrelatedJitterBackoff) Next(retry int) (time.Duration, bool) {
+	if b.maxCalls > 0 && retry == b.maxCalls {
+		return 0, false
+	}
+	if b.start.IsZero() {
+		b.start = b.opt.now()
+	}
+
+	lo, hi := float64(b.base), float64(b.prev)*3
+	wait := time.Duration(math.Min(lo+b.opt.rand()*(hi-lo), float64(b.max)))
+	if b.opt.expired(b.start, wait) {
+		return 0, false
+	}
+	b.prev = wait
+	return wait, true
+}
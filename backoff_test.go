@@ -0,0 +1,405 @@
+package httpc_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/httpc"
+	"github.com/jsteenb2/httpc/httpctest"
+)
+
+func TestFullJitterBackoff(t *testing.T) {
+	t.Run("waits are scaled by the random source", func(t *testing.T) {
+		randVals := []float64{0, 0.5, 0.5, 0.5, 0.999}
+		boffer := httpc.NewFullJitterBackoff(100*time.Millisecond, time.Second, 0,
+			httpc.JitterRand(seqRand(randVals...)),
+		)()
+
+		expected := []time.Duration{
+			0,
+			100 * time.Millisecond,
+			200 * time.Millisecond,
+			400 * time.Millisecond,
+			999 * time.Millisecond,
+		}
+		for i, want := range expected {
+			wait, ok := boffer.Next(i + 1)
+			mustEquals(t, true, ok)
+			equals(t, want, wait)
+		}
+	})
+
+	t.Run("stops at max calls", func(t *testing.T) {
+		boffer := httpc.NewFullJitterBackoff(time.Millisecond, time.Second, 3)()
+
+		_, ok := boffer.Next(2)
+		equals(t, true, ok)
+		_, ok = boffer.Next(3)
+		equals(t, false, ok)
+	})
+
+	t.Run("distribution is within the ceiling", func(t *testing.T) {
+		boffer := httpc.NewFullJitterBackoff(10*time.Millisecond, 80*time.Millisecond, 0,
+			httpc.JitterRand(rand.New(rand.NewSource(1)).Float64),
+		)()
+
+		for retry := 1; retry < 100; retry++ {
+			ceil := 10 * time.Millisecond << uint(retry-1)
+			if ceil > 80*time.Millisecond || ceil <= 0 {
+				ceil = 80 * time.Millisecond
+			}
+			wait, ok := boffer.Next(retry)
+			mustEquals(t, true, ok)
+			if wait < 0 || wait >= ceil {
+				t.Fatalf("retry %d: wait %s outside [0, %s)", retry, wait, ceil)
+			}
+		}
+	})
+
+	t.Run("stops after max elapsed", func(t *testing.T) {
+		now := time.Unix(0, 0)
+		boffer := httpc.NewFullJitterBackoff(time.Second, time.Minute, 0,
+			httpc.JitterRand(seqRand(0.5)),
+			httpc.JitterNow(func() time.Time { return now }),
+			httpc.JitterMaxElapsed(10*time.Second),
+		)()
+
+		_, ok := boffer.Next(1)
+		equals(t, true, ok)
+
+		now = now.Add(9500 * time.Millisecond)
+		_, ok = boffer.Next(2)
+		equals(t, false, ok)
+	})
+}
+
+func TestDecorrelatedJitterBackoff(t *testing.T) {
+	t.Run("waits grow from the previous wait", func(t *testing.T) {
+		boffer := httpc.NewDecorrelatedJitterBackoff(100*time.Millisecond, time.Second, 0,
+			httpc.JitterRand(seqRand(0.5, 0.5, 1, 0)),
+		)()
+
+		expected := []time.Duration{
+			200 * time.Millisecond, // 100ms + 0.5*(300ms-100ms)
+			350 * time.Millisecond, // 100ms + 0.5*(600ms-100ms)
+			time.Second,            // capped at the max
+			100 * time.Millisecond, // never below the base
+		}
+		for i, want := range expected {
+			wait, ok := boffer.Next(i + 1)
+			mustEquals(t, true, ok)
+			equals(t, want, wait)
+		}
+	})
+
+	t.Run("stops at max calls", func(t *testing.T) {
+		boffer := httpc.NewDecorrelatedJitterBackoff(time.Millisecond, time.Second, 2)()
+
+		_, ok := boffer.Next(1)
+		equals(t, true, ok)
+		_, ok = boffer.Next(2)
+		equals(t, false, ok)
+	})
+
+	t.Run("distribution is within the base and max", func(t *testing.T) {
+		boffer := httpc.NewDecorrelatedJitterBackoff(10*time.Millisecond, 80*time.Millisecond, 0,
+			httpc.JitterRand(rand.New(rand.NewSource(1)).Float64),
+		)()
+
+		prev := 10 * time.Millisecond
+		for retry := 1; retry < 100; retry++ {
+			wait, ok := boffer.Next(retry)
+			mustEquals(t, true, ok)
+			if wait < 10*time.Millisecond || wait > 80*time.Millisecond || wait > 3*prev {
+				t.Fatalf("retry %d: wait %s outside [10ms, min(80ms, %s)]", retry, wait, 3*prev)
+			}
+			prev = wait
+		}
+	})
+
+	t.Run("stops after max elapsed", func(t *testing.T) {
+		now := time.Unix(0, 0)
+		boffer := httpc.NewDecorrelatedJitterBackoff(time.Second, time.Minute, 0,
+			httpc.JitterRand(seqRand(0)),
+			httpc.JitterNow(func() time.Time { return now }),
+			httpc.JitterMaxElapsed(5*time.Second),
+		)()
+
+		_, ok := boffer.Next(1)
+		equals(t, true, ok)
+
+		now = now.Add(4500 * time.Millisecond)
+		_, ok = boffer.Next(2)
+		equals(t, false, ok)
+	})
+}
+
+// seqRand returns the values in order, repeating the last value.
+func seqRand(vals ...float64) func() float64 {
+	var i int
+	return func() float64 {
+		v := vals[i]
+		if i < len(vals)-1 {
+			i++
+		}
+		return v
+	}
+}
+
+func TestRetryClock(t *testing.T) {
+	t.Run("waits on the client clock", func(t *testing.T) {
+		clock := httpctest.NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+		doer := new(fakeDoer)
+		doer.doFn = func(*http.Request) (*http.Response, error) {
+			resp := stubResp(http.StatusServiceUnavailable)
+			switch doer.doCallCount {
+			case 1:
+				resp.Header = http.Header{"Retry-After": []string{clock.Now().Add(10 * time.Second).Format(http.TimeFormat)}}
+			case 3:
+				resp = stubResp(http.StatusOK)
+			}
+			return resp, nil
+		}
+
+		client := httpc.New(doer,
+			httpc.WithBackoff(httpc.NewConstantBackoff(time.Second, 5)),
+			httpc.WithClock(clock),
+		)
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- client.
+				Get("/foo").
+				Success(httpc.StatusOK()).
+				Retry(httpc.RetryStatus(httpc.StatusServiceUnavailable())).
+				Do(context.TODO())
+		}()
+
+		clock.BlockUntil(1)
+		clock.Advance(9 * time.Second)
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+
+		mustNoError(t, <-errs)
+
+		waits := clock.Waits()
+		mustEquals(t, 2, len(waits))
+		equals(t, 10*time.Second, waits[0])
+		equals(t, time.Second, waits[1])
+		equals(t, 3, doer.doCallCount)
+	})
+}
+
+func TestRetryHooks(t *testing.T) {
+	t.Run("notifies each retry and give up", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusServiceUnavailable)
+
+		var calls []string
+		var attempts []int
+		var msgs []string
+		client := httpc.New(doer,
+			httpc.WithBackoff(httpc.NewConstantBackoff(time.Nanosecond, 3)),
+			httpc.WithOnRetry(func(ctx context.Context, attempt int, err error, wait time.Duration) {
+				calls = append(calls, "client")
+				attempts = append(attempts, attempt)
+				msgs = append(msgs, httpc.BackoffMessage(err))
+				equals(t, time.Nanosecond, wait)
+			}),
+		)
+
+		var giveUpAttempts int
+		var giveUpErr error
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Retry(httpc.RetryStatus(httpc.StatusServiceUnavailable())).
+			OnRetry(func(ctx context.Context, attempt int, err error, wait time.Duration) {
+				calls = append(calls, "request")
+			}).
+			OnGiveUp(func(ctx context.Context, attempts int, err error) {
+				giveUpAttempts = attempts
+				giveUpErr = err
+			}).
+			Do(context.TODO())
+		mustError(t, err)
+
+		mustEquals(t, 4, len(calls))
+		equals(t, "client", calls[0])
+		equals(t, "request", calls[1])
+
+		mustEquals(t, 2, len(attempts))
+		equals(t, 1, attempts[0])
+		equals(t, 2, attempts[1])
+		equals(t, "status=503", msgs[0])
+
+		equals(t, 3, giveUpAttempts)
+		equals(t, err, giveUpErr)
+		equals(t, 3, doer.doCallCount)
+	})
+
+	t.Run("does not give up on non retriable errors", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusBadRequest)
+
+		var gaveUp bool
+		client := httpc.New(doer,
+			httpc.WithBackoff(httpc.NewConstantBackoff(time.Nanosecond, 3)),
+			httpc.WithOnGiveUp(func(ctx context.Context, attempts int, err error) {
+				gaveUp = true
+			}),
+		)
+
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Retry(httpc.RetryStatus(httpc.StatusServiceUnavailable())).
+			Do(context.TODO())
+		mustError(t, err)
+
+		equals(t, false, gaveUp)
+		equals(t, 1, doer.doCallCount)
+	})
+
+	t.Run("gives up when the budget is exhausted", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusServiceUnavailable)
+
+		var giveUpErr error
+		client := httpc.New(doer,
+			httpc.WithBackoff(httpc.NewConstantBackoff(time.Nanosecond, 10)),
+			httpc.WithRetryBudget(httpc.NewRetryBudget(time.Minute, 0, 0)),
+			httpc.WithOnGiveUp(func(ctx context.Context, attempts int, err error) {
+				giveUpErr = err
+			}),
+		)
+
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Retry(httpc.RetryStatus(httpc.StatusServiceUnavailable())).
+			Do(context.TODO())
+		mustError(t, err)
+
+		var budgetErr *httpc.RetryBudgetErr
+		equals(t, true, errors.As(giveUpErr, &budgetErr))
+	})
+}
+
+func TestRetryTimeouts(t *testing.T) {
+	t.Run("attempt timeout is retried", func(t *testing.T) {
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			if doer.doCallCount == 1 {
+				<-r.Context().Done()
+				return nil, r.Context().Err()
+			}
+			return stubResp(http.StatusOK), nil
+		}
+
+		client := httpc.New(doer,
+			httpc.WithBackoff(httpc.NewConstantBackoff(time.Nanosecond, 3)),
+			httpc.WithAttemptTimeout(10*time.Millisecond),
+		)
+
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, 2, doer.doCallCount)
+	})
+
+	t.Run("attempt timeout error is retriable", func(t *testing.T) {
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			<-r.Context().Done()
+			return nil, r.Context().Err()
+		}
+
+		client := httpc.New(doer)
+
+		err := client.
+			Get("/foo").
+			AttemptTimeout(time.Millisecond).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustError(t, err)
+
+		equals(t, true, retryErr(err))
+		equals(t, true, errors.Is(err, context.DeadlineExceeded))
+	})
+
+	t.Run("caller cancellation is not retried", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			cancel()
+			return nil, r.Context().Err()
+		}
+
+		client := httpc.New(doer,
+			httpc.WithBackoff(httpc.NewConstantBackoff(time.Nanosecond, 3)),
+			httpc.WithAttemptTimeout(time.Second),
+		)
+
+		err := client.Get("/foo").Success(httpc.StatusOK()).Do(ctx)
+		mustError(t, err)
+
+		equals(t, false, retryErr(err))
+		equals(t, 1, doer.doCallCount)
+	})
+
+	t.Run("stops when the wait exceeds the deadline", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusServiceUnavailable)
+
+		var gaveUp bool
+		client := httpc.New(doer,
+			httpc.WithBackoff(httpc.NewConstantBackoff(time.Hour, 3)),
+			httpc.WithTimeout(time.Second),
+			httpc.WithOnGiveUp(func(ctx context.Context, attempts int, err error) {
+				gaveUp = true
+			}),
+		)
+
+		start := time.Now()
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Retry(httpc.RetryStatus(httpc.StatusServiceUnavailable())).
+			Do(context.TODO())
+		mustError(t, err)
+
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Errorf("expected retry loop to stop early: took %s", elapsed)
+		}
+		equals(t, true, retryErr(err))
+		equals(t, true, gaveUp)
+		equals(t, 1, doer.doCallCount)
+	})
+
+	t.Run("request timeout overrides client", func(t *testing.T) {
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			<-r.Context().Done()
+			return nil, r.Context().Err()
+		}
+
+		client := httpc.New(doer, httpc.WithTimeout(time.Hour))
+
+		err := client.
+			Get("/foo").
+			Timeout(5 * time.Millisecond).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustError(t, err)
+
+		equals(t, true, errors.Is(err, context.DeadlineExceeded))
+	})
+}
@@ -0,0 +1,203 @@
+package httpc
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync/atomic"
+)
+
+// defaultBodySpillThreshold is the size past which an encoded request body is
+// buffered in a temp file rather than in memory.
+const defaultBodySpillThreshold = 32 << 20
+
+// requestBody is the body of the attempts of a request.
+type requestBody interface {
+	// setOn sets the body of the attempt's request.
+	setOn(req *http.Request) error
+	// contentType returns the Content-Type the body is sent with, if any, when
+	// the request does not set one.
+	contentType() string
+	// replayable reports whether the body can be sent by more than one attempt.
+	replayable() bool
+	// concurrentReplayable reports whether the body can be sent by more than one
+	// attempt at the same time, i.e. by a hedged attempt.
+	concurrentReplayable() bool
+	close() error
+}
+
+// replayBody is an encoded request body that is buffered so that it can be read
+// any number of times, by retries, redirects and signing.
+type replayBody struct {
+	buf   []byte
+	file  *os.File
+	size  int64
+	cType string
+}
+
+// newReplayBody buffers the body in memory, spilling to a temp file once the
+// body is larger than threshold bytes.
+// The body is sent with the Content-Type cType, when not empty.
+func newReplayBody(r io.Reader, threshold int64, cType string) (*replayBody, error) {
+	if buf, ok := r.(*bytes.Buffer); ok && int64(buf.Len()) <= threshold {
+		return &replayBody{buf: buf.Bytes(), size: int64(buf.Len()), cType: cType}, nil
+	}
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, r, threshold+1)
+	if err == io.EOF {
+		return &replayBody{buf: buf.Bytes(), size: n, cType: cType}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := ioutil.TempFile("", "httpc-body-*")
+	if err != nil {
+		return nil, err
+	}
+	body := &replayBody{file: f, cType: cType}
+	if body.size, err = io.Copy(f, io.MultiReader(&buf, r)); err != nil {
+		body.close()
+		return nil, err
+	}
+	return body, nil
+}
+
+// setOn sets the body of the request, along with its GetBody and length.
+func (b *replayBody) setOn(req *http.Request) error {
+	req.ContentLength = b.size
+	if b.size == 0 {
+		req.Body = http.NoBody
+		req.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+		return nil
+	}
+	req.Body = b.reader()
+	req.GetBody = func() (io.ReadCloser, error) { return b.reader(), nil }
+	return nil
+}
+
+func (b *replayBody) contentType() string {
+	return b.cType
+}
+
+func (b *replayBody) replayable() bool {
+	return true
+}
+
+func (b *replayBody) concurrentReplayable() bool {
+	return true
+}
+
+func (b *replayBody) reader() io.ReadCloser {
+	if b.file != nil {
+		return ioutil.NopCloser(io.NewSectionReader(b.file, 0, b.size))
+	}
+	return ioutil.NopCloser(bytes.NewReader(b.buf))
+}
+
+// close removes the temp file the body was spilled to.
+func (b *replayBody) close() error {
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	if rmErr := os.Remove(b.file.Name()); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// streamBody is a raw request body streamed from a reader, without encoding or
+// buffering. It can only be resent when a rewind func is provided.
+type streamBody struct {
+	r      io.Reader
+	size   int64
+	rewind func() (io.Reader, error)
+	sent   bool
+
+	// stale is set once GetBody is called, i.e. by an Authorizer that signs a
+	// digest of the body, as the reader rewind returns may share its offset with
+	// the body of the request, i.e. a file seeked to its start.
+	stale atomic.Bool
+}
+
+func (b *streamBody) setOn(req *http.Request) error {
+	if b.sent {
+		if b.rewind == nil {
+			return ErrBodyNotReplayable
+		}
+		r, err := b.rewind()
+		if err != nil {
+			return err
+		}
+		b.r = r
+	}
+	b.sent = true
+	b.stale.Store(false)
+
+	req.Body = &streamReader{body: b}
+	req.ContentLength = b.size
+	if b.size < 0 {
+		req.ContentLength = -1
+	}
+	if b.size == 0 {
+		req.Body = http.NoBody
+	}
+	if rewind := b.rewind; rewind != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			b.stale.Store(true)
+			r, err := rewind()
+			if err != nil {
+				return nil, err
+			}
+			return ioutil.NopCloser(r), nil
+		}
+	}
+	return nil
+}
+
+func (b *streamBody) contentType() string {
+	return ""
+}
+
+func (b *streamBody) replayable() bool {
+	return b.rewind != nil
+}
+
+// concurrentReplayable is false, as the readers rewind returns may share their
+// offset, i.e. a file seeked to its start.
+func (b *streamBody) concurrentReplayable() bool {
+	return false
+}
+
+func (b *streamBody) close() error {
+	return nil
+}
+
+// streamReader is the body of a request sent with a streamBody. It rewinds the
+// body before it is first read when GetBody has read it since it was set.
+type streamReader struct {
+	body    *streamBody
+	started bool
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	if !s.started {
+		s.started = true
+		if s.body.stale.Load() {
+			r, err := s.body.rewind()
+			if err != nil {
+				return 0, err
+			}
+			s.body.r = r
+		}
+	}
+	return s.body.r.Read(p)
+}
+
+func (s *streamReader) Close() error {
+	return nil
+}
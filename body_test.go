@@ -0,0 +1,270 @@
+package httpc_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/httpc"
+)
+
+func TestReplayableBody(t *testing.T) {
+	t.Run("encodes once across retries", func(t *testing.T) {
+		var bodies []string
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				return nil, err
+			}
+			bodies = append(bodies, string(b))
+			return stubResp(http.StatusServiceUnavailable), nil
+		}
+
+		var encodes int
+		encodeFn := httpc.EncodeFn(func(v interface{}) (io.Reader, error) {
+			encodes++
+			return strings.NewReader(v.(string)), nil
+		})
+
+		client := httpc.New(doer,
+			httpc.WithBackoff(httpc.NewConstantBackoff(time.Nanosecond, 3)),
+			httpc.WithEncoder(encodeFn),
+		)
+
+		err := client.
+			Post("/foo").
+			Body("payload").
+			Success(httpc.StatusOK()).
+			Retry(httpc.RetryStatus(httpc.StatusServiceUnavailable())).
+			Do(context.TODO())
+		mustError(t, err)
+
+		equals(t, 1, encodes)
+		mustEquals(t, 3, len(bodies))
+		for _, b := range bodies {
+			equals(t, "payload", b)
+		}
+	})
+
+	t.Run("sets GetBody and content length", func(t *testing.T) {
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			mustEquals(t, true, r.GetBody != nil)
+			equals(t, int64(len(`{"Name":"name","S":"","Method":""}`+"\n")), r.ContentLength)
+
+			first, err := ioutil.ReadAll(r.Body)
+			mustNoError(t, err)
+			rc, err := r.GetBody()
+			mustNoError(t, err)
+			replayed, err := ioutil.ReadAll(rc)
+			mustNoError(t, err)
+			equals(t, string(first), string(replayed))
+			return stubResp(http.StatusOK), nil
+		}
+
+		client := httpc.New(doer)
+
+		err := client.
+			Post("/foo").
+			Body(foo{Name: "name"}).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+	})
+
+	t.Run("spills large bodies to a temp file", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("TMPDIR", dir)
+
+		payload := bytes.Repeat([]byte("a"), 1024)
+
+		var tempFiles int
+		var bodies [][]byte
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			entries, err := os.ReadDir(dir)
+			mustNoError(t, err)
+			tempFiles = len(entries)
+
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				return nil, err
+			}
+			bodies = append(bodies, b)
+			return stubResp(http.StatusServiceUnavailable), nil
+		}
+
+		client := httpc.New(doer,
+			httpc.WithBackoff(httpc.NewConstantBackoff(time.Nanosecond, 2)),
+			httpc.WithBodySpillThreshold(100),
+			httpc.WithEncoder(httpc.EncodeFn(func(v interface{}) (io.Reader, error) {
+				return bytes.NewReader(v.([]byte)), nil
+			})),
+		)
+
+		err := client.
+			Put("/foo").
+			Body(payload).
+			Success(httpc.StatusOK()).
+			Retry(httpc.RetryStatus(httpc.StatusServiceUnavailable())).
+			Do(context.TODO())
+		mustError(t, err)
+
+		equals(t, 1, tempFiles)
+		mustEquals(t, 2, len(bodies))
+		for _, b := range bodies {
+			equals(t, true, bytes.Equal(payload, b))
+		}
+
+		entries, err := os.ReadDir(dir)
+		mustNoError(t, err)
+		equals(t, 0, len(entries))
+	})
+}
+
+func TestBodyReader(t *testing.T) {
+	t.Run("streams with content length", func(t *testing.T) {
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			equals(t, int64(7), r.ContentLength)
+			b, err := ioutil.ReadAll(r.Body)
+			mustNoError(t, err)
+			equals(t, "payload", string(b))
+			return stubResp(http.StatusOK), nil
+		}
+
+		client := httpc.New(doer, httpc.WithEncoder(httpc.EncodeFn(func(interface{}) (io.Reader, error) {
+			t.Fatal("unexpected call to encoder")
+			return nil, nil
+		})))
+
+		err := client.
+			Put("/foo").
+			BodyReader(strings.NewReader("payload"), 7).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+	})
+
+	t.Run("unknown size is sent chunked", func(t *testing.T) {
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(r.TransferEncoding) != 1 || r.TransferEncoding[0] != "chunked" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			b, _ := ioutil.ReadAll(r.Body)
+			if string(b) != "payload" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer svr.Close()
+
+		client := httpc.New(svr.Client(), httpc.WithBaseURL(svr.URL))
+
+		err := client.
+			Post("/foo").
+			BodyReader(ioutil.NopCloser(strings.NewReader("payload")), -1).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+	})
+
+	t.Run("is not retried without rewind", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusServiceUnavailable)
+
+		client := httpc.New(doer, httpc.WithBackoff(httpc.NewConstantBackoff(time.Nanosecond, 3)))
+
+		err := client.
+			Put("/foo").
+			BodyReader(strings.NewReader("payload"), 7).
+			Success(httpc.StatusOK()).
+			Retry(httpc.RetryStatus(httpc.StatusServiceUnavailable())).
+			Do(context.TODO())
+		mustError(t, err)
+
+		equals(t, false, retryErr(err))
+		equals(t, 1, doer.doCallCount)
+	})
+
+	t.Run("is retried with rewind", func(t *testing.T) {
+		var bodies []string
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				return nil, err
+			}
+			bodies = append(bodies, string(b))
+			return stubResp(http.StatusServiceUnavailable), nil
+		}
+
+		client := httpc.New(doer, httpc.WithBackoff(httpc.NewConstantBackoff(time.Nanosecond, 3)))
+
+		f := strings.NewReader("payload")
+		err := client.
+			Put("/foo").
+			BodyReader(f, f.Size()).
+			BodyRewind(func() (io.Reader, error) {
+				_, err := f.Seek(0, io.SeekStart)
+				return f, err
+			}).
+			Success(httpc.StatusOK()).
+			Retry(httpc.RetryStatus(httpc.StatusServiceUnavailable())).
+			Do(context.TODO())
+		mustError(t, err)
+
+		mustEquals(t, 3, len(bodies))
+		for _, b := range bodies {
+			equals(t, "payload", b)
+		}
+	})
+
+	t.Run("is rewound after signing", func(t *testing.T) {
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			b, err := ioutil.ReadAll(r.Body)
+			mustNoError(t, err)
+			equals(t, "payload", string(b))
+
+			digest := sha256.Sum256([]byte("payload"))
+			equals(t, hex.EncodeToString(digest[:]), r.Header.Get("X-Digest"))
+			return stubResp(http.StatusOK), nil
+		}
+
+		auth := httpc.HMACAuth([]byte("secret"), httpc.HMACDigestHeader("X-Digest"))
+		client := httpc.New(doer, httpc.WithAuth(auth))
+
+		f, err := ioutil.TempFile("", "httpc-test-*")
+		mustNoError(t, err)
+		defer os.Remove(f.Name())
+		defer f.Close()
+		_, err = f.WriteString("payload")
+		mustNoError(t, err)
+		_, err = f.Seek(0, io.SeekStart)
+		mustNoError(t, err)
+
+		err = client.
+			Put("/foo").
+			BodyReader(f, 7).
+			BodyRewind(func() (io.Reader, error) {
+				_, err := f.Seek(0, io.SeekStart)
+				return f, err
+			}).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+		equals(t, 1, doer.doCallCount)
+	})
+}
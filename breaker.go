@@ -0,0 +1,340 @@
+package httpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is the error wrapped by the HTTPErr returned when a request is
+// short-circuited by an open circuit breaker.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a circuit.
+type BreakerState int
+
+// Circuit states.
+const (
+	// BreakerClosed allows all requests through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects all requests until the cooldown elapses.
+	BreakerOpen
+	// BreakerHalfOpen allows a limited number of probe requests through.
+	BreakerHalfOpen
+)
+
+// String returns the name of the state.
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+type breakerOpt struct {
+	consecutiveFailures int
+	failureRatio        float64
+	minRequests         int
+	interval            time.Duration
+	cooldown            time.Duration
+	halfOpenRequests    int
+	keyFn               func(*http.Request) string
+	failureStatus       StatusFn
+	onStateChange       func(key string, from, to BreakerState)
+}
+
+// BreakerOptFn is a optional parameter for a CircuitBreaker.
+type BreakerOptFn func(o breakerOpt) breakerOpt
+
+// BreakerConsecutiveFailures trips the circuit after n consecutive failures.
+// Defaults to 5. Set to 0 to disable.
+func BreakerConsecutiveFailures(n int) BreakerOptFn {
+	return func(o breakerOpt) breakerOpt {
+		o.consecutiveFailures = n
+		return o
+	}
+}
+
+// BreakerFailureRatio trips the circuit when the ratio of failures to requests
+// reaches ratio, once at least minRequests have been made. Disabled by default.
+func BreakerFailureRatio(ratio float64, minRequests int) BreakerOptFn {
+	return func(o breakerOpt) breakerOpt {
+		o.failureRatio = ratio
+		o.minRequests = minRequests
+		return o
+	}
+}
+
+// BreakerInterval sets the period after which the counts of a closed circuit
+// are cleared. By default counts are only cleared on a state change.
+func BreakerInterval(d time.Duration) BreakerOptFn {
+	return func(o breakerOpt) breakerOpt {
+		o.interval = d
+		return o
+	}
+}
+
+// BreakerCooldown sets how long a circuit stays open before allowing probe
+// requests through. Defaults to 30 seconds.
+func BreakerCooldown(d time.Duration) BreakerOptFn {
+	return func(o breakerOpt) breakerOpt {
+		o.cooldown = d
+		return o
+	}
+}
+
+// BreakerHalfOpenRequests sets the number of probe requests allowed through a
+// half-open circuit. The circuit closes once that many probes succeed. Defaults to 1.
+func BreakerHalfOpenRequests(n int) BreakerOptFn {
+	return func(o breakerOpt) breakerOpt {
+		o.halfOpenRequests = n
+		return o
+	}
+}
+
+// BreakerKey sets the func that keys a request to its circuit. Defaults to
+// the request's host.
+func BreakerKey(fn func(*http.Request) string) BreakerOptFn {
+	return func(o breakerOpt) breakerOpt {
+		o.keyFn = fn
+		return o
+	}
+}
+
+// BreakerRouteKey keys circuits by the request's host, method and path.
+func BreakerRouteKey() BreakerOptFn {
+	return BreakerKey(func(r *http.Request) string {
+		return r.URL.Host + " " + r.Method + " " + r.URL.Path
+	})
+}
+
+// BreakerFailureStatus sets the status codes that are counted as failures.
+// Defaults to any 5xx status code. Response errors are always failures.
+func BreakerFailureStatus(fn StatusFn) BreakerOptFn {
+	return func(o breakerOpt) breakerOpt {
+		o.failureStatus = fn
+		return o
+	}
+}
+
+// BreakerOnStateChange sets a callback that is called whenever a circuit
+// changes state. The callback is called after the breaker's lock is released,
+// so it may call State, but it must not block.
+func BreakerOnStateChange(fn func(key string, from, to BreakerState)) BreakerOptFn {
+	return func(o breakerOpt) breakerOpt {
+		o.onStateChange = fn
+		return o
+	}
+}
+
+// CircuitBreaker tracks failures of requests made by a client, keyed by host
+// or route. Once a circuit trips, requests to it are short-circuited with an
+// HTTPErr that provides a CircuitOpen() bool that is true, until the cooldown
+// elapses and probe requests succeed. A CircuitBreaker is safe for concurrent use.
+type CircuitBreaker struct {
+	opt breakerOpt
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+	changes  []stateChange
+}
+
+type stateChange struct {
+	key      string
+	from, to BreakerState
+}
+
+// NewCircuitBreaker creates a CircuitBreaker.
+func NewCircuitBreaker(opts ...BreakerOptFn) *CircuitBreaker {
+	opt := breakerOpt{
+		consecutiveFailures: 5,
+		cooldown:            30 * time.Second,
+		halfOpenRequests:    1,
+		keyFn:               func(r *http.Request) string { return r.URL.Host },
+		failureStatus:       StatusInRange(http.StatusInternalServerError, 599),
+	}
+	for _, o := range opts {
+		opt = o(opt)
+	}
+
+	return &CircuitBreaker{
+		opt:      opt,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// State returns the current state of the circuit for the key.
+func (cb *CircuitBreaker) State(key string) BreakerState {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	c, ok := cb.circuits[key]
+	if !ok {
+		return BreakerClosed
+	}
+	cb.refresh(key, c, time.Now())
+	return c.state
+}
+
+// allow checks the request's circuit. When the request is allowed through, the
+// returned func must be called with the outcome of the request. A request that
+// is cancelled before a response is received counts as neither a success nor a
+// failure.
+func (cb *CircuitBreaker) allow(req *http.Request) (func(*http.Response, error), error) {
+	key := cb.opt.keyFn(req)
+
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	c, ok := cb.circuits[key]
+	if !ok {
+		c = &circuit{state: BreakerClosed}
+		cb.circuits[key] = c
+		cb.resetCounts(c, time.Now())
+	}
+	cb.refresh(key, c, time.Now())
+
+	switch c.state {
+	case BreakerOpen:
+		return nil, ErrCircuitOpen
+	case BreakerHalfOpen:
+		if c.requests >= cb.opt.halfOpenRequests {
+			return nil, ErrCircuitOpen
+		}
+	}
+	c.requests++
+
+	generation := c.generation
+	return func(resp *http.Response, err error) {
+		if resp == nil && err != nil && (req.Context().Err() != nil || errors.Is(err, context.Canceled)) {
+			cb.release(key, generation)
+			return
+		}
+		failed := err != nil || (resp != nil && cb.opt.failureStatus(resp.StatusCode))
+		cb.done(key, generation, failed)
+	}, nil
+}
+
+// release returns the request slot of a request that has no outcome, so that
+// a half-open circuit allows another probe through.
+func (cb *CircuitBreaker) release(key string, generation uint64) {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	c := cb.circuits[key]
+	cb.refresh(key, c, time.Now())
+	if c.generation == generation && c.requests > 0 {
+		c.requests--
+	}
+}
+
+func (cb *CircuitBreaker) done(key string, generation uint64, failed bool) {
+	cb.mu.Lock()
+	defer cb.unlock()
+
+	c := cb.circuits[key]
+	now := time.Now()
+	cb.refresh(key, c, now)
+	if c.generation != generation {
+		return
+	}
+
+	if failed {
+		c.failures++
+		c.consecutiveFailures++
+		switch {
+		case c.state == BreakerHalfOpen:
+			cb.setState(key, c, BreakerOpen, now)
+		case cb.tripped(c):
+			cb.setState(key, c, BreakerOpen, now)
+		}
+		return
+	}
+
+	c.successes++
+	c.consecutiveFailures = 0
+	if c.state == BreakerHalfOpen && c.successes >= cb.opt.halfOpenRequests {
+		cb.setState(key, c, BreakerClosed, now)
+	}
+}
+
+func (cb *CircuitBreaker) tripped(c *circuit) bool {
+	if n := cb.opt.consecutiveFailures; n > 0 && c.consecutiveFailures >= n {
+		return true
+	}
+	if cb.opt.failureRatio > 0 && c.requests >= cb.opt.minRequests {
+		return float64(c.failures)/float64(c.requests) >= cb.opt.failureRatio
+	}
+	return false
+}
+
+// refresh moves an open circuit to half-open after the cooldown, and clears the
+// counts of a closed circuit when its interval has elapsed.
+func (cb *CircuitBreaker) refresh(key string, c *circuit, now time.Time) {
+	switch c.state {
+	case BreakerClosed:
+		if !c.expiry.IsZero() && !now.Before(c.expiry) {
+			cb.resetCounts(c, now)
+		}
+	case BreakerOpen:
+		if !now.Before(c.expiry) {
+			cb.setState(key, c, BreakerHalfOpen, now)
+		}
+	}
+}
+
+func (cb *CircuitBreaker) setState(key string, c *circuit, state BreakerState, now time.Time) {
+	prev := c.state
+	c.state = state
+	cb.resetCounts(c, now)
+
+	if cb.opt.onStateChange != nil {
+		cb.changes = append(cb.changes, stateChange{key: key, from: prev, to: state})
+	}
+}
+
+// unlock releases the breaker's lock, and then calls the state change callback
+// with the changes made while it was held.
+func (cb *CircuitBreaker) unlock() {
+	changes := cb.changes
+	cb.changes = nil
+	cb.mu.Unlock()
+
+	for _, c := range changes {
+		cb.opt.onStateChange(c.key, c.from, c.to)
+	}
+}
+
+func (cb *CircuitBreaker) resetCounts(c *circuit, now time.Time) {
+	c.generation++
+	c.requests, c.failures, c.successes, c.consecutiveFailures = 0, 0, 0, 0
+
+	c.expiry = time.Time{}
+	switch c.state {
+	case BreakerClosed:
+		if cb.opt.interval > 0 {
+			c.expiry = now.Add(cb.opt.interval)
+		}
+	case BreakerOpen:
+		c.expiry = now.Add(cb.opt.cooldown)
+	}
+}
+
+type circuit struct {
+	state      BreakerState
+	generation uint64
+	expiry     time.Time
+
+	requests            int
+	failures            int
+	successes           int
+	consecutiveFailures int
+}
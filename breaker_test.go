@@ -0,0 +1,239 @@
+package httpc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/httpc"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Run("trips after consecutive failures", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusServiceUnavailable)
+
+		var changes []string
+		cb := httpc.NewCircuitBreaker(
+			httpc.BreakerConsecutiveFailures(3),
+			httpc.BreakerOnStateChange(func(key string, from, to httpc.BreakerState) {
+				changes = append(changes, key+":"+from.String()+"->"+to.String())
+			}),
+		)
+		client := httpc.New(doer, httpc.WithCircuitBreaker(cb))
+
+		for i := 0; i < 5; i++ {
+			err := client.
+				Get("http://example.com/foo").
+				Success(httpc.StatusOK()).
+				Do(context.TODO())
+			mustError(t, err)
+
+			equals(t, i >= 3, circuitOpenErr(err))
+		}
+
+		equals(t, 3, doer.doCallCount)
+		equals(t, httpc.BreakerOpen, cb.State("example.com"))
+		mustEquals(t, 1, len(changes))
+		equals(t, "example.com:closed->open", changes[0])
+	})
+
+	t.Run("open circuit error is distinguishable", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusInternalServerError)
+
+		cb := httpc.NewCircuitBreaker(httpc.BreakerConsecutiveFailures(1))
+		client := httpc.New(doer,
+			httpc.WithCircuitBreaker(cb),
+			httpc.WithBackoff(httpc.NewConstantBackoff(time.Nanosecond, 5)),
+		)
+
+		err := client.
+			Get("http://example.com/foo").
+			Success(httpc.StatusOK()).
+			Retry(httpc.RetryStatus(httpc.StatusInternalServerError())).
+			Do(context.TODO())
+		mustError(t, err)
+
+		equals(t, true, circuitOpenErr(err))
+		equals(t, false, retryErr(err))
+		equals(t, true, errors.Is(err, httpc.ErrCircuitOpen))
+		equals(t, 1, doer.doCallCount)
+	})
+
+	t.Run("half open probe closes circuit", func(t *testing.T) {
+		status := http.StatusInternalServerError
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			return stubResp(status), nil
+		}
+
+		var changes []httpc.BreakerState
+		cb := httpc.NewCircuitBreaker(
+			httpc.BreakerConsecutiveFailures(1),
+			httpc.BreakerCooldown(time.Millisecond),
+			httpc.BreakerOnStateChange(func(key string, from, to httpc.BreakerState) {
+				changes = append(changes, to)
+			}),
+		)
+		client := httpc.New(doer, httpc.WithCircuitBreaker(cb))
+
+		req := func() error {
+			return client.
+				Get("http://example.com/foo").
+				Success(httpc.StatusOK()).
+				Do(context.TODO())
+		}
+
+		mustError(t, req())
+		time.Sleep(5 * time.Millisecond)
+
+		status = http.StatusOK
+		mustNoError(t, req())
+		mustNoError(t, req())
+
+		equals(t, httpc.BreakerClosed, cb.State("example.com"))
+		mustEquals(t, 3, len(changes))
+		equals(t, httpc.BreakerOpen, changes[0])
+		equals(t, httpc.BreakerHalfOpen, changes[1])
+		equals(t, httpc.BreakerClosed, changes[2])
+	})
+
+	t.Run("failure ratio", func(t *testing.T) {
+		var count int
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			count++
+			if count%2 == 0 {
+				return nil, errors.New("connection reset")
+			}
+			return stubResp(http.StatusOK), nil
+		}
+
+		cb := httpc.NewCircuitBreaker(
+			httpc.BreakerConsecutiveFailures(0),
+			httpc.BreakerFailureRatio(0.5, 4),
+		)
+		client := httpc.New(doer, httpc.WithCircuitBreaker(cb))
+
+		for i := 0; i < 4; i++ {
+			client.Get("http://example.com/foo").Success(httpc.StatusOK()).Do(context.TODO())
+		}
+
+		equals(t, httpc.BreakerOpen, cb.State("example.com"))
+	})
+
+	t.Run("cancelled requests are not counted", func(t *testing.T) {
+		var count int
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			count++
+			if count == 2 {
+				<-r.Context().Done()
+				return nil, r.Context().Err()
+			}
+			return stubResp(http.StatusServiceUnavailable), nil
+		}
+
+		cb := httpc.NewCircuitBreaker(httpc.BreakerConsecutiveFailures(2))
+		client := httpc.New(doer, httpc.WithCircuitBreaker(cb))
+
+		req := func(ctx context.Context) error {
+			return client.
+				Get("http://example.com/foo").
+				Success(httpc.StatusOK()).
+				Do(ctx)
+		}
+
+		mustError(t, req(context.TODO()))
+
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+		mustError(t, req(ctx))
+
+		mustError(t, req(context.TODO()))
+
+		equals(t, 3, doer.doCallCount)
+		equals(t, httpc.BreakerOpen, cb.State("example.com"))
+	})
+
+	t.Run("cancelled probe releases the half open slot", func(t *testing.T) {
+		var cancelled bool
+		status := http.StatusInternalServerError
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			if cancelled {
+				<-r.Context().Done()
+				return nil, r.Context().Err()
+			}
+			return stubResp(status), nil
+		}
+
+		cb := httpc.NewCircuitBreaker(
+			httpc.BreakerConsecutiveFailures(1),
+			httpc.BreakerCooldown(time.Millisecond),
+		)
+		client := httpc.New(doer, httpc.WithCircuitBreaker(cb))
+
+		mustError(t, client.Get("http://example.com/foo").Success(httpc.StatusOK()).Do(context.TODO()))
+		time.Sleep(5 * time.Millisecond)
+
+		cancelled = true
+		ctx, cancel := context.WithTimeout(context.TODO(), time.Millisecond)
+		defer cancel()
+		err := client.Get("http://example.com/foo").Success(httpc.StatusOK()).Do(ctx)
+		mustError(t, err)
+		equals(t, false, circuitOpenErr(err))
+		equals(t, httpc.BreakerHalfOpen, cb.State("example.com"))
+
+		cancelled, status = false, http.StatusOK
+		mustNoError(t, client.Get("http://example.com/foo").Success(httpc.StatusOK()).Do(context.TODO()))
+		equals(t, httpc.BreakerClosed, cb.State("example.com"))
+	})
+
+	t.Run("state change callback may read the state", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusServiceUnavailable)
+
+		var states []httpc.BreakerState
+		var cb *httpc.CircuitBreaker
+		cb = httpc.NewCircuitBreaker(
+			httpc.BreakerConsecutiveFailures(1),
+			httpc.BreakerOnStateChange(func(key string, from, to httpc.BreakerState) {
+				states = append(states, cb.State(key))
+			}),
+		)
+		client := httpc.New(doer, httpc.WithCircuitBreaker(cb))
+
+		mustError(t, client.Get("http://example.com/foo").Success(httpc.StatusOK()).Do(context.TODO()))
+
+		mustEquals(t, 1, len(states))
+		equals(t, httpc.BreakerOpen, states[0])
+	})
+
+	t.Run("circuits are keyed by host", func(t *testing.T) {
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			if r.URL.Host == "down.example.com" {
+				return stubResp(http.StatusBadGateway), nil
+			}
+			return stubResp(http.StatusOK), nil
+		}
+
+		cb := httpc.NewCircuitBreaker(httpc.BreakerConsecutiveFailures(1))
+		client := httpc.New(doer, httpc.WithCircuitBreaker(cb))
+
+		mustError(t, client.Get("http://down.example.com/foo").Success(httpc.StatusOK()).Do(context.TODO()))
+		mustNoError(t, client.Get("http://up.example.com/foo").Success(httpc.StatusOK()).Do(context.TODO()))
+
+		equals(t, httpc.BreakerOpen, cb.State("down.example.com"))
+		equals(t, httpc.BreakerClosed, cb.State("up.example.com"))
+	})
+}
+
+func circuitOpenErr(err error) bool {
+	type circuitOpener interface {
+		CircuitOpen() bool
+	}
+	c, ok := err.(circuitOpener)
+	return ok && c.CircuitOpen()
+}
@@ -0,0 +1,106 @@
+package httpc
+
+import (
+	"sync"
+	"time"
+)
+
+// budgetBuckets is the number of buckets the retry budget's window is split into.
+const budgetBuckets = 10
+
+// RetryBudgetErr is returned when a retry is denied because the retry budget is
+// exhausted. It wraps the error of the last attempt.
+type RetryBudgetErr struct {
+	err error
+}
+
+// Error returns the error message of the last attempt.
+func (e *RetryBudgetErr) Error() string {
+	return "retry budget exhausted: " + e.err.Error()
+}
+
+// Unwrap returns the error of the last attempt.
+func (e *RetryBudgetErr) Unwrap() error {
+	return e.err
+}
+
+// Retry provides the retry behavior, a budget error is never retried.
+func (e *RetryBudgetErr) Retry() bool {
+	return false
+}
+
+// RetryBudget limits the retries made across all requests of a client, so that
+// retries can not amplify an outage. Within a sliding window of ttl, retries
+// are allowed up to a percentage of the successful requests plus a minimum rate
+// of retries per second. A RetryBudget is safe for concurrent use.
+type RetryBudget struct {
+	percent   float64
+	minPerSec float64
+	ttl       time.Duration
+
+	mu      sync.Mutex
+	buckets [budgetBuckets]budgetBucket
+}
+
+type budgetBucket struct {
+	idx                   int64
+	deposits, withdrawals float64
+}
+
+// NewRetryBudget creates a RetryBudget. The percentCanRetry is the ratio of
+// retries to successful requests, i.e. 0.2 allows 1 retry for every 5 successful
+// requests. The minRetriesPerSec allows a minimum rate of retries when few
+// requests are being made.
+func NewRetryBudget(ttl time.Duration, minRetriesPerSec int, percentCanRetry float64) *RetryBudget {
+	return &RetryBudget{
+		percent:   percentCanRetry,
+		minPerSec: float64(minRetriesPerSec),
+		ttl:       ttl,
+	}
+}
+
+// deposit records a successful request.
+func (b *RetryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bucket(time.Now()).deposits++
+}
+
+// tryWithdraw reports whether a retry is allowed, recording the retry if so.
+func (b *RetryBudget) tryWithdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	current := b.bucket(now)
+
+	var deposits, withdrawals float64
+	for _, bk := range b.buckets {
+		if bk.idx > current.idx-budgetBuckets {
+			deposits += bk.deposits
+			withdrawals += bk.withdrawals
+		}
+	}
+
+	allowed := b.percent*deposits + b.minPerSec*b.ttl.Seconds()
+	if withdrawals+1 > allowed {
+		return false
+	}
+	current.withdrawals++
+	return true
+}
+
+func (b *RetryBudget) bucket(now time.Time) *budgetBucket {
+	width := int64(b.ttl / budgetBuckets)
+	if width <= 0 {
+		width = 1
+	}
+	idx := now.UnixNano() / width
+
+	bk := &b.buckets[idx%budgetBuckets]
+	if bk.idx != idx {
+		*bk = budgetBucket{idx: idx}
+	}
+	return bk
+}
@@ -0,0 +1,89 @@
+package httpc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/httpc"
+)
+
+func TestRetryBudget(t *testing.T) {
+	t.Run("denies retries without successful requests", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusServiceUnavailable)
+
+		client := httpc.New(doer,
+			httpc.WithBackoff(httpc.NewConstantBackoff(time.Nanosecond, 10)),
+			httpc.WithRetryBudget(httpc.NewRetryBudget(time.Minute, 0, 0.5)),
+		)
+
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Retry(httpc.RetryStatus(httpc.StatusNotIn(http.StatusOK))).
+			Do(context.TODO())
+		mustError(t, err)
+
+		var budgetErr *httpc.RetryBudgetErr
+		mustEquals(t, true, errors.As(err, &budgetErr))
+		equals(t, false, retryErr(err))
+
+		var httpErr *httpc.HTTPErr
+		equals(t, true, errors.As(err, &httpErr))
+		equals(t, 1, doer.doCallCount)
+	})
+
+	t.Run("allows retries as a percentage of successes", func(t *testing.T) {
+		status := http.StatusOK
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			return stubResp(status), nil
+		}
+
+		client := httpc.New(doer,
+			httpc.WithBackoff(httpc.NewConstantBackoff(time.Nanosecond, 10)),
+			httpc.WithRetryBudget(httpc.NewRetryBudget(time.Minute, 0, 0.5)),
+		)
+
+		req := func() error {
+			return client.
+				Get("/foo").
+				Success(httpc.StatusOK()).
+				Retry(httpc.RetryStatus(httpc.StatusNotIn(http.StatusOK))).
+				Do(context.TODO())
+		}
+
+		for i := 0; i < 4; i++ {
+			mustNoError(t, req())
+		}
+
+		status = http.StatusServiceUnavailable
+		doer.doCallCount = 0
+		err := req()
+		mustError(t, err)
+
+		var budgetErr *httpc.RetryBudgetErr
+		equals(t, true, errors.As(err, &budgetErr))
+		equals(t, 3, doer.doCallCount)
+	})
+
+	t.Run("minimum retry rate", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusServiceUnavailable)
+
+		client := httpc.New(doer,
+			httpc.WithBackoff(httpc.NewConstantBackoff(time.Nanosecond, 10)),
+			httpc.WithRetryBudget(httpc.NewRetryBudget(time.Second, 2, 0)),
+		)
+
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Retry(httpc.RetryStatus(httpc.StatusNotIn(http.StatusOK))).
+			Do(context.TODO())
+		mustError(t, err)
+
+		equals(t, 3, doer.doCallCount)
+	})
+}
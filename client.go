@@ -2,7 +2,9 @@ package httpc
 
 import (
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 )
 
 // Doer is an abstraction around a http client.
@@ -10,6 +12,21 @@ type Doer interface {
 	Do(*http.Request) (*http.Response, error)
 }
 
+// DoerFunc is an adapter to allow the use of ordinary functions as a Doer.
+type DoerFunc func(*http.Request) (*http.Response, error)
+
+// Do calls f(r).
+func (f DoerFunc) Do(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// Middleware wraps a Doer to provide behavior around every http call, i.e.
+// logging, tracing or header injection. Middleware is applied to each attempt
+// made by a request. It sees the request after headers, query params and the
+// Authorizer are applied, and the response before the status is evaluated against
+// the request's success, retry, not found and exists funcs.
+type Middleware func(Doer) Doer
+
 // Client is the httpc client. The client sets the default backoff and encode func
 // on the request that are created when making an http call. Those defaults can be
 // overridden in the request builder.
@@ -17,19 +34,38 @@ type Client struct {
 	baseURL string
 	doer    Doer
 
-	headers []kvPair
+	headers    []kvPair
+	middleware []Middleware
 
-	authFn   AuthFn
-	encodeFn EncodeFn
+	auth     Authorizer
+	encoder  Encoder
+	codecs   Codecs
 	backoff  BackoffOptFn
+	breaker  *CircuitBreaker
+	budget   *RetryBudget
+	limiter  *RateLimiter
+	hedger   *Hedger
+	clock    Clock
+	onRetry  []RetryNotifyFn
+	onGiveUp []GiveUpFn
+
+	maxRetryWait   time.Duration
+	attemptTimeout time.Duration
+	timeout        time.Duration
+	spillThreshold int64
 }
 
-// New returns a new client.
+// New returns a new client. Request bodies are json encoded by default, with a
+// Content-Type of application/json and without setting the Accept header.
 func New(doer Doer, opts ...ClientOptFn) *Client {
 	c := Client{
-		doer:     doer,
-		encodeFn: JSONEncode(),
-		backoff:  NewStopBackoff(),
+		doer:    doer,
+		encoder: NewEncoder(JSONEncode(), "application/json", ""),
+		codecs:  DefaultCodecs(),
+		backoff: NewStopBackoff(),
+		clock:   realClock{},
+
+		spillThreshold: defaultBodySpillThreshold,
 	}
 
 	for _, o := range opts {
@@ -81,16 +117,39 @@ func (c *Client) Put(addr string) *Request {
 // Req makes an http request.
 func (c *Client) Req(method, addr string) *Request {
 	address := c.baseURL + addr
-	if !strings.HasSuffix(c.baseURL, "/") && !strings.HasPrefix(addr, "/") {
+	if isAbsURL(addr) {
+		address = addr
+	} else if !strings.HasSuffix(c.baseURL, "/") && !strings.HasPrefix(addr, "/") {
 		address = c.baseURL + "/" + addr
 	}
 	return &Request{
-		Method:   method,
-		Addr:     address,
-		headers:  c.headers,
-		doer:     c.doer,
-		authFn:   c.authFn,
-		encodeFn: c.encodeFn,
-		backoff:  c.backoff,
+		Method:     method,
+		Addr:       address,
+		headers:    c.headers,
+		doer:       c.doer,
+		middleware: append([]Middleware(nil), c.middleware...),
+		auth:       c.auth,
+		encoder:    c.encoder,
+		codecs:     c.codecs,
+		backoff:    c.backoff,
+		breaker:    c.breaker,
+		budget:     c.budget,
+		limiter:    c.limiter,
+		hedger:     c.hedger,
+		clock:      c.clock,
+		onRetry:    append([]RetryNotifyFn(nil), c.onRetry...),
+		onGiveUp:   append([]GiveUpFn(nil), c.onGiveUp...),
+
+		maxRetryWait:   c.maxRetryWait,
+		attemptTimeout: c.attemptTimeout,
+		timeout:        c.timeout,
+		spillThreshold: c.spillThreshold,
 	}
 }
+
+// isAbsURL reports whether the addr is an absolute url, with a scheme and host,
+// that is not joined to the base url.
+func isAbsURL(addr string) bool {
+	u, err := url.Parse(addr)
+	return err == nil && u.IsAbs() && u.Host != ""
+}
@@ -287,6 +287,36 @@ func TestClient_Req(t *testing.T) {
 		})
 	})
 
+	t.Run("address", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			baseURL  string
+			addr     string
+			expected string
+		}{
+			{name: "relative path without base url", addr: "foo", expected: "/foo"},
+			{name: "absolute path without base url", addr: "/foo", expected: "/foo"},
+			{name: "relative path joined to base url", baseURL: "http://example.com", addr: "foo", expected: "http://example.com/foo"},
+			{name: "absolute url without base url", addr: "https://auth.example.com/token", expected: "https://auth.example.com/token"},
+			{name: "absolute url ignores base url", baseURL: "http://example.com", addr: "https://auth.example.com/token", expected: "https://auth.example.com/token"},
+		}
+
+		for _, tt := range tests {
+			fn := func(t *testing.T) {
+				doer := newHappyDoer(http.StatusOK)
+
+				client := httpc.New(doer, httpc.WithBaseURL(tt.baseURL))
+
+				err := client.Get(tt.addr).Success(httpc.StatusOK()).Do(context.TODO())
+				mustNoError(t, err)
+
+				mustEquals(t, 1, len(doer.args))
+				equals(t, tt.expected, doer.args[0].URL.String())
+			}
+			t.Run(tt.name, fn)
+		}
+	})
+
 	t.Run("gob encoding", func(t *testing.T) {
 		doer := new(fakeDoer)
 		doer.doFn = func(r *http.Request) (*http.Response, error) {
@@ -430,6 +460,106 @@ func TestClient_Req(t *testing.T) {
 		})
 	})
 
+	t.Run("retry after", func(t *testing.T) {
+		t.Run("server delay capped by max wait", func(t *testing.T) {
+			doer := new(fakeDoer)
+			doer.doFn = func(r *http.Request) (*http.Response, error) {
+				if doer.doCallCount == 1 {
+					resp := stubResp(http.StatusTooManyRequests)
+					resp.Header = http.Header{"Retry-After": {"120"}}
+					return resp, nil
+				}
+				return stubResp(http.StatusOK), nil
+			}
+
+			client := httpc.New(doer,
+				httpc.WithBackoff(httpc.NewConstantBackoff(time.Nanosecond, 3)),
+				httpc.WithMaxRetryWait(20*time.Millisecond),
+			)
+
+			start := time.Now()
+			err := client.
+				Get("/foo").
+				Success(httpc.StatusOK()).
+				Retry(httpc.RetryStatus(httpc.StatusTooManyRequests())).
+				Do(context.TODO())
+			mustNoError(t, err)
+
+			equals(t, 2, doer.doCallCount)
+			if elapsed := time.Since(start); elapsed < 20*time.Millisecond || elapsed > time.Second {
+				t.Errorf("unexpected wait: %s", elapsed)
+			}
+		})
+
+		t.Run("parses headers", func(t *testing.T) {
+			tests := []struct {
+				name     string
+				status   int
+				header   http.Header
+				expected time.Duration
+				ok       bool
+			}{
+				{
+					name:     "retry after seconds",
+					status:   http.StatusServiceUnavailable,
+					header:   http.Header{"Retry-After": {"30"}},
+					expected: 30 * time.Second,
+					ok:       true,
+				},
+				{
+					name:     "retry after date in past",
+					status:   http.StatusServiceUnavailable,
+					header:   http.Header{"Retry-After": {"Wed, 21 Oct 2015 07:28:00 GMT"}},
+					expected: 0,
+					ok:       true,
+				},
+				{
+					name:     "rate limit reset seconds",
+					status:   http.StatusTooManyRequests,
+					header:   http.Header{"X-Ratelimit-Reset": {"5"}},
+					expected: 5 * time.Second,
+					ok:       true,
+				},
+				{
+					name:   "rate limit not exhausted",
+					status: http.StatusInternalServerError,
+					header: http.Header{"X-Ratelimit-Reset": {"5"}, "X-Ratelimit-Remaining": {"10"}},
+				},
+				{
+					name:   "no header",
+					status: http.StatusServiceUnavailable,
+				},
+			}
+
+			for _, tt := range tests {
+				fn := func(t *testing.T) {
+					doer := new(fakeDoer)
+					doer.doFn = func(r *http.Request) (*http.Response, error) {
+						resp := stubResp(tt.status)
+						resp.Header = tt.header
+						return resp, nil
+					}
+
+					err := httpc.New(doer).
+						Get("/foo").
+						Success(httpc.StatusOK()).
+						Do(context.TODO())
+					mustError(t, err)
+
+					ra, ok := err.(interface {
+						RetryAfter() (time.Duration, bool)
+					})
+					mustEquals(t, true, ok)
+
+					d, ok := ra.RetryAfter()
+					equals(t, tt.ok, ok)
+					equals(t, tt.expected, d)
+				}
+				t.Run(tt.name, fn)
+			}
+		})
+	})
+
 	t.Run("response error handled", func(t *testing.T) {
 		doer := new(fakeDoer)
 		doer.doFn = func(req *http.Request) (*http.Response, error) {
@@ -683,6 +813,87 @@ func TestClient_Req(t *testing.T) {
 		})
 	})
 
+	t.Run("middleware", func(t *testing.T) {
+		t.Run("applied in order", func(t *testing.T) {
+			doer := newHappyDoer(http.StatusOK)
+
+			var calls []string
+			newMW := func(name string) httpc.Middleware {
+				return func(next httpc.Doer) httpc.Doer {
+					return httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+						calls = append(calls, name+":"+r.Header.Get("Authorization"))
+						return next.Do(r)
+					})
+				}
+			}
+
+			client := httpc.New(doer,
+				httpc.WithAuth(httpc.BearerTokenAuth("token")),
+				httpc.WithMiddleware(newMW("client1"), newMW("client2")),
+			)
+
+			err := client.
+				Get("/foo").
+				Use(newMW("req1"), newMW("req2")).
+				Success(httpc.StatusOK()).
+				Do(context.TODO())
+			mustNoError(t, err)
+
+			expected := []string{"client1", "client2", "req1", "req2"}
+			mustEquals(t, len(expected), len(calls))
+			for i, name := range expected {
+				equals(t, name+":Bearer token", calls[i])
+			}
+		})
+
+		t.Run("applied to every attempt", func(t *testing.T) {
+			doer := newHappyDoer(http.StatusInternalServerError)
+
+			var count int
+			mw := func(next httpc.Doer) httpc.Doer {
+				return httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+					count++
+					return next.Do(r)
+				})
+			}
+
+			client := httpc.New(doer, httpc.WithBackoff(httpc.NewConstantBackoff(time.Nanosecond, 3)))
+
+			err := client.
+				Get("/foo").
+				Use(mw).
+				Success(httpc.StatusOK()).
+				Retry(httpc.RetryStatus(httpc.StatusInternalServerError())).
+				Do(context.TODO())
+			mustError(t, err)
+
+			equals(t, 3, count)
+		})
+
+		t.Run("can replace the response", func(t *testing.T) {
+			doer := newHappyDoer(http.StatusInternalServerError)
+
+			mw := func(next httpc.Doer) httpc.Doer {
+				return httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+					resp, err := next.Do(r)
+					if err != nil {
+						return nil, err
+					}
+					resp.StatusCode = http.StatusOK
+					return resp, nil
+				})
+			}
+
+			client := httpc.New(doer, httpc.WithMiddleware(mw))
+
+			err := client.
+				Get("/foo").
+				Success(httpc.StatusOK()).
+				Do(context.TODO())
+			mustNoError(t, err)
+		})
+	})
+
 	t.Run("auth", func(t *testing.T) {
 		t.Run("basic auth", func(t *testing.T) {
 			doer := new(fakeDoer)
@@ -728,6 +939,51 @@ func TestClient_Req(t *testing.T) {
 
 			equals(t, "Bearer token", actual.Name)
 		})
+
+		t.Run("authorizer error is not sent", func(t *testing.T) {
+			doer := newHappyDoer(http.StatusOK)
+
+			authErr := errors.New("signing failed")
+			client := httpc.New(doer, httpc.WithAuth(httpc.AuthorizerFunc(func(ctx context.Context, r *http.Request) (*http.Request, error) {
+				return nil, authErr
+			})))
+
+			err := client.
+				Get("/foo").
+				Success(httpc.StatusOK()).
+				Do(context.TODO())
+			mustError(t, err)
+
+			equals(t, true, errors.Is(err, authErr))
+			equals(t, false, retryErr(err))
+			equals(t, 0, doer.doCallCount)
+		})
+
+		t.Run("retryable authorizer error is retried", func(t *testing.T) {
+			doer := newHappyDoer(http.StatusOK)
+
+			var count int
+			authorizer := httpc.AuthorizerFunc(func(ctx context.Context, r *http.Request) (*http.Request, error) {
+				count++
+				if count < 3 {
+					return nil, &fakeRetryErr{errors.New("token unavailable")}
+				}
+				return httpc.BearerTokenAuth("token")(r), nil
+			})
+
+			client := httpc.New(doer, httpc.WithBackoff(httpc.NewConstantBackoff(time.Nanosecond, 5)))
+
+			err := client.
+				Get("/foo").
+				Auth(authorizer).
+				Success(httpc.StatusOK()).
+				Do(context.TODO())
+			mustNoError(t, err)
+
+			equals(t, 3, count)
+			mustEquals(t, 1, len(doer.args))
+			equals(t, "Bearer token", doer.args[0].Header.Get("Authorization"))
+		})
 	})
 }
 
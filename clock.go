@@ -0,0 +1,45 @@
+package httpc
+
+import "time"
+
+// Clock provides the time to the retry loop, allowing tests to control the
+// passage of time. The httpctest package provides a fake implementation.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTimer creates a Timer that sends the current time on its channel
+	// after at least duration d.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single event timer created by a Clock.
+type Timer interface {
+	// C returns the channel the time is delivered on when the timer fires.
+	C() <-chan time.Time
+	// Stop prevents the Timer from firing, returning false if the timer has
+	// already fired or been stopped.
+	Stop() bool
+}
+
+// realClock is the Clock backed by the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.t.Stop()
+}
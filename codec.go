@@ -0,0 +1,81 @@
+package httpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"strings"
+)
+
+// ErrUnexpectedContentType is returned when the Content-Type of a response has
+// no decoder registered in the client's codecs.
+var ErrUnexpectedContentType = errors.New("unexpected content type")
+
+// DecodeFactory constructs the DecodeFn for the target v, i.e. JSONDecode.
+type DecodeFactory func(v interface{}) DecodeFn
+
+// Codecs is a registry of decoders keyed by media type. A key of a structured
+// syntax suffix, i.e. "+json", matches any media type with that suffix, such as
+// application/problem+json.
+type Codecs map[string]DecodeFactory
+
+// DefaultCodecs returns the codecs for JSON, XML, gob, url encoded forms and
+// plain text.
+func DefaultCodecs() Codecs {
+	return Codecs{
+		"application/json":  JSONDecode,
+		"+json":             JSONDecode,
+		"application/xml":   XMLDecode,
+		"text/xml":          XMLDecode,
+		"+xml":              XMLDecode,
+		"application/x-gob": GobDecode,
+		FormContentType:     FormDecode,
+		"text/plain":        TextDecode,
+	}
+}
+
+// decoder returns the DecodeFn of v for the content type.
+func (c Codecs) decoder(contentType string, v interface{}) (DecodeFn, error) {
+	if contentType == "" {
+		return nil, fmt.Errorf("%w: response has no Content-Type", ErrUnexpectedContentType)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %s", ErrUnexpectedContentType, contentType, err)
+	}
+
+	if fn, ok := c[mediaType]; ok {
+		return fn(v), nil
+	}
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		if fn, ok := c[mediaType[i:]]; ok {
+			return fn(v), nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnexpectedContentType, mediaType)
+}
+
+// TextDecode sets the client's decodeFn to a plain text decoder. It decodes into
+// a *string, a *[]byte or an io.Writer.
+func TextDecode(v interface{}) DecodeFn {
+	return func(r io.Reader) error {
+		switch t := v.(type) {
+		case *string:
+			b, err := ioutil.ReadAll(r)
+			*t = string(b)
+			return err
+		case *[]byte:
+			b, err := ioutil.ReadAll(r)
+			*t = b
+			return err
+		case io.Writer:
+			_, err := io.Copy(t, r)
+			return err
+		default:
+			return fmt.Errorf("text decoding requires a *string, *[]byte or io.Writer: got %T", v)
+		}
+	}
+}
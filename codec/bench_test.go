@@ -0,0 +1,84 @@
+package codec_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/jsteenb2/httpc"
+	"github.com/jsteenb2/httpc/codec/cbor"
+	"github.com/jsteenb2/httpc/codec/msgpack"
+)
+
+type metric struct {
+	Name      string            `json:"name" msgpack:"name"`
+	Host      string            `json:"host" msgpack:"host"`
+	Timestamp int64             `json:"ts" msgpack:"ts"`
+	Value     float64           `json:"value" msgpack:"value"`
+	Tags      map[string]string `json:"tags" msgpack:"tags"`
+	Samples   []float64         `json:"samples" msgpack:"samples"`
+}
+
+func newMetric() metric {
+	return metric{
+		Name:      "http.server.duration",
+		Host:      "web-01",
+		Timestamp: 1700000000000,
+		Value:     12.5,
+		Tags: map[string]string{
+			"method": "GET",
+			"route":  "/api/v1/items",
+			"status": "200",
+		},
+		Samples: []float64{9.1, 10.4, 12.5, 14.2, 31.7},
+	}
+}
+
+var codecs = []struct {
+	name string
+	enc  httpc.Encoder
+	dec  httpc.DecodeFactory
+}{
+	{name: "json", enc: httpc.JSONEncoder(), dec: httpc.JSONDecode},
+	{name: "gob", enc: httpc.GobEncoder(), dec: httpc.GobDecode},
+	{name: "msgpack", enc: msgpack.Encoder(), dec: msgpack.Decode},
+	{name: "cbor", enc: cbor.Encoder(), dec: cbor.Decode},
+}
+
+func BenchmarkEncode(b *testing.B) {
+	m := newMetric()
+	for _, c := range codecs {
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.enc.Encode(m); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	for _, c := range codecs {
+		b.Run(c.name, func(b *testing.B) {
+			r, err := c.enc.Encode(newMetric())
+			if err != nil {
+				b.Fatal(err)
+			}
+			body, err := ioutil.ReadAll(r)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var m metric
+				if err := c.dec(&m)(bytes.NewReader(body)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Package cbor provides CBOR, RFC 8949, encoders and decoders for httpc.
+package cbor
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/jsteenb2/httpc"
+)
+
+// ContentType is the media type of CBOR.
+const ContentType = "application/cbor"
+
+// the default modes are created once, as building a mode validates its options.
+var (
+	defaultEncMode, _ = cbor.EncOptions{}.EncMode()
+	defaultDecMode, _ = cbor.DecOptions{}.DecMode()
+)
+
+// Encode sets the client's encodeFn to a cbor encoder. Struct fields are named
+// by their cbor tags, or their json tags when absent, as defined by
+// github.com/fxamacker/cbor. It does not set the Content-Type of the request,
+// use Encoder for an encoder that does.
+func Encode() httpc.EncodeFn {
+	return encode(defaultEncMode)
+}
+
+// Encoder sets the client's encoder to a cbor encoder, with a Content-Type and
+// Accept of application/cbor.
+func Encoder() httpc.Encoder {
+	return EncoderWithOptions(defaultEncMode)
+}
+
+// EncoderWithOptions returns a cbor encoder that marshals with the encoding
+// mode, i.e. one created from cbor.CanonicalEncOptions.
+func EncoderWithOptions(em cbor.EncMode) httpc.Encoder {
+	return httpc.NewEncoder(encode(em), ContentType, ContentType)
+}
+
+func encode(em cbor.EncMode) httpc.EncodeFn {
+	return func(v interface{}) (io.Reader, error) {
+		b, err := em.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewBuffer(b), nil
+	}
+}
+
+// Decode sets the client's decodeFn to a cbor decoder.
+func Decode(v interface{}) httpc.DecodeFn {
+	return Decoder(defaultDecMode)(v)
+}
+
+// Decoder returns a factory of cbor decoders that unmarshal with the decoding
+// mode.
+func Decoder(dm cbor.DecMode) httpc.DecodeFactory {
+	return func(v interface{}) httpc.DecodeFn {
+		return func(r io.Reader) error {
+			return dm.NewDecoder(r).Decode(v)
+		}
+	}
+}
+
+// Register adds the cbor decoder to the codecs, for the application/cbor media
+// type and the +cbor structured syntax suffix.
+func Register(codecs httpc.Codecs) httpc.Codecs {
+	codecs[ContentType] = Decode
+	codecs["+cbor"] = Decode
+	return codecs
+}
@@ -0,0 +1,94 @@
+package codec_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/jsteenb2/httpc"
+	"github.com/jsteenb2/httpc/codec/cbor"
+	"github.com/jsteenb2/httpc/codec/msgpack"
+)
+
+func TestCodecs(t *testing.T) {
+	tests := []struct {
+		name        string
+		encoder     httpc.Encoder
+		register    func(httpc.Codecs) httpc.Codecs
+		decode      httpc.DecodeFactory
+		contentType string
+		// respType is the alternate media type the body is echoed back with,
+		// which is decoded by the registered codec.
+		respType string
+		invalid  []byte
+	}{
+		{
+			name:        "msgpack",
+			encoder:     msgpack.Encoder(),
+			register:    msgpack.Register,
+			decode:      msgpack.Decode,
+			contentType: msgpack.ContentType,
+			respType:    "application/x-msgpack",
+			invalid:     []byte{0xc1},
+		},
+		{
+			name:        "cbor",
+			encoder:     cbor.Encoder(),
+			register:    cbor.Register,
+			decode:      cbor.Decode,
+			contentType: cbor.ContentType,
+			respType:    "application/senml+cbor",
+			invalid:     []byte{0xff},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Run("round trip", func(t *testing.T) {
+				doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+					if ct := r.Header.Get("Content-Type"); ct != tt.contentType {
+						t.Errorf("expected content type %q, got %q", tt.contentType, ct)
+					}
+					if accept := r.Header.Get("Accept"); accept != tt.contentType {
+						t.Errorf("expected accept %q, got %q", tt.contentType, accept)
+					}
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": {tt.respType}},
+						Body:       r.Body,
+					}, nil
+				})
+
+				client := httpc.New(doer,
+					httpc.WithEncoder(tt.encoder),
+					httpc.WithCodecs(tt.register(httpc.DefaultCodecs())),
+				)
+
+				expected := newMetric()
+				var actual metric
+				err := client.
+					Post("/foo").
+					Body(expected).
+					Success(httpc.StatusOK()).
+					DecodeContent(&actual).
+					Do(context.TODO())
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(expected, actual) {
+					t.Errorf("expected: %+v\tgot: %+v", expected, actual)
+				}
+			})
+
+			t.Run("decode error", func(t *testing.T) {
+				var actual metric
+				if err := tt.decode(&actual)(bytes.NewReader(tt.invalid)); err == nil {
+					t.Fatal("expected error but none received")
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,16 @@
+module github.com/jsteenb2/httpc/codec
+
+go 1.21
+
+require (
+	github.com/fxamacker/cbor/v2 v2.9.2
+	github.com/jsteenb2/httpc v0.0.0-00010101000000-000000000000
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+)
+
+require (
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+)
+
+replace github.com/jsteenb2/httpc => ..
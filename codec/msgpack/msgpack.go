@@ -0,0 +1,86 @@
+// Package msgpack provides MessagePack encoders and decoders for httpc. Along
+// with package cbor, it is part of the github.com/jsteenb2/httpc/codec module,
+// leaving the httpc module free of dependencies.
+package msgpack
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/jsteenb2/httpc"
+)
+
+// ContentType is the media type of MessagePack.
+const ContentType = "application/msgpack"
+
+// Encode sets the client's encodeFn to a msgpack encoder. Struct fields are
+// named by their msgpack tags, as defined by github.com/vmihailenco/msgpack. It
+// does not set the Content-Type of the request, use Encoder for an encoder that
+// does.
+func Encode() httpc.EncodeFn {
+	return encode(nil)
+}
+
+// Encoder sets the client's encoder to a msgpack encoder, with a Content-Type
+// and Accept of application/msgpack.
+func Encoder() httpc.Encoder {
+	return EncoderWithOptions()
+}
+
+// EncoderWithOptions returns a msgpack encoder that configures the
+// msgpack.Encoder with the options before encoding, i.e. with
+// (*msgpack.Encoder).UseCompactInts.
+func EncoderWithOptions(opts ...func(*msgpack.Encoder)) httpc.Encoder {
+	return httpc.NewEncoder(encode(opts), ContentType, ContentType)
+}
+
+func encode(opts []func(*msgpack.Encoder)) httpc.EncodeFn {
+	return func(v interface{}) (io.Reader, error) {
+		enc := msgpack.GetEncoder()
+		defer msgpack.PutEncoder(enc)
+
+		var buf bytes.Buffer
+		enc.Reset(&buf)
+		for _, o := range opts {
+			o(enc)
+		}
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+		return &buf, nil
+	}
+}
+
+// Decode sets the client's decodeFn to a msgpack decoder.
+func Decode(v interface{}) httpc.DecodeFn {
+	return Decoder()(v)
+}
+
+// Decoder returns a factory of msgpack decoders that configure the
+// msgpack.Decoder with the options before decoding, i.e. with
+// (*msgpack.Decoder).DisallowUnknownFields.
+func Decoder(opts ...func(*msgpack.Decoder)) httpc.DecodeFactory {
+	return func(v interface{}) httpc.DecodeFn {
+		return func(r io.Reader) error {
+			dec := msgpack.GetDecoder()
+			defer msgpack.PutDecoder(dec)
+
+			dec.Reset(r)
+			for _, o := range opts {
+				o(dec)
+			}
+			return dec.Decode(v)
+		}
+	}
+}
+
+// Register adds the msgpack decoder to the codecs, for the application/msgpack,
+// application/x-msgpack and application/vnd.msgpack media types.
+func Register(codecs httpc.Codecs) httpc.Codecs {
+	codecs[ContentType] = Decode
+	codecs["application/x-msgpack"] = Decode
+	codecs["application/vnd.msgpack"] = Decode
+	return codecs
+}
@@ -0,0 +1,10 @@
+module github.com/jsteenb2/httpc/codec/protobuf
+
+go 1.21
+
+require (
+	github.com/jsteenb2/httpc v0.0.0-00010101000000-000000000000
+	google.golang.org/protobuf v1.36.5
+)
+
+replace github.com/jsteenb2/httpc => ../..
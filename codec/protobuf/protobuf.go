@@ -0,0 +1,167 @@
+// Package protobuf provides Protocol Buffers encoders and decoders for httpc,
+// in both the binary wire format and the protobuf JSON mapping. It is its own
+// module, so google.golang.org/protobuf is only required by the modules that
+// import it.
+package protobuf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/jsteenb2/httpc"
+)
+
+// ContentType is the media type of the protobuf binary wire format.
+const ContentType = "application/x-protobuf"
+
+// JSONContentType is the media type of the protobuf JSON mapping.
+const JSONContentType = "application/json"
+
+var messageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
+// Encode sets the client's encodeFn to a protobuf encoder. The body must be a
+// proto.Message. It does not set the Content-Type of the request, use Encoder
+// for an encoder that does.
+func Encode() httpc.EncodeFn {
+	return encode(proto.MarshalOptions{})
+}
+
+// Encoder sets the client's encoder to a protobuf encoder, with a Content-Type
+// and Accept of application/x-protobuf.
+func Encoder() httpc.Encoder {
+	return EncoderWithOptions(proto.MarshalOptions{})
+}
+
+// EncoderWithOptions returns a protobuf encoder that marshals with the options,
+// i.e. deterministically.
+func EncoderWithOptions(opts proto.MarshalOptions) httpc.Encoder {
+	return httpc.NewEncoder(encode(opts), ContentType, ContentType)
+}
+
+func encode(opts proto.MarshalOptions) httpc.EncodeFn {
+	return func(v interface{}) (io.Reader, error) {
+		m, ok := v.(proto.Message)
+		if !ok {
+			return nil, fmt.Errorf("protobuf encoding requires a proto.Message: got %T", v)
+		}
+		b, err := opts.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewBuffer(b), nil
+	}
+}
+
+// Decode sets the client's decodeFn to a protobuf decoder. The target v must be
+// a proto.Message, or a pointer to one, i.e. when used with the typed httpc.Do.
+func Decode(v interface{}) httpc.DecodeFn {
+	return Decoder(proto.UnmarshalOptions{})(v)
+}
+
+// Decoder returns a factory of protobuf decoders that unmarshal with the options.
+func Decoder(opts proto.UnmarshalOptions) httpc.DecodeFactory {
+	return func(v interface{}) httpc.DecodeFn {
+		return func(r io.Reader) error {
+			m, err := message(v)
+			if err != nil {
+				return err
+			}
+			b, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			return opts.Unmarshal(b, m)
+		}
+	}
+}
+
+// JSONEncode sets the client's encodeFn to a protobuf JSON encoder. The body
+// must be a proto.Message. It does not set the Content-Type of the request, use
+// JSONEncoder for an encoder that does.
+func JSONEncode() httpc.EncodeFn {
+	return jsonEncode(protojson.MarshalOptions{})
+}
+
+// JSONEncoder sets the client's encoder to a protobuf JSON encoder, with a
+// Content-Type and Accept of application/json.
+func JSONEncoder() httpc.Encoder {
+	return JSONEncoderWithOptions(protojson.MarshalOptions{})
+}
+
+// JSONEncoderWithOptions returns a protobuf JSON encoder that marshals with the
+// options, i.e. emitting unpopulated fields.
+func JSONEncoderWithOptions(opts protojson.MarshalOptions) httpc.Encoder {
+	return httpc.NewEncoder(jsonEncode(opts), JSONContentType, JSONContentType)
+}
+
+func jsonEncode(opts protojson.MarshalOptions) httpc.EncodeFn {
+	return func(v interface{}) (io.Reader, error) {
+		m, ok := v.(proto.Message)
+		if !ok {
+			return nil, fmt.Errorf("protobuf json encoding requires a proto.Message: got %T", v)
+		}
+		b, err := opts.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewBuffer(b), nil
+	}
+}
+
+// JSONDecode sets the client's decodeFn to a protobuf JSON decoder, that
+// ignores unknown fields so that additions to a service's API do not break its
+// clients. The target v must be a proto.Message, or a pointer to one.
+func JSONDecode(v interface{}) httpc.DecodeFn {
+	return JSONDecoder(protojson.UnmarshalOptions{DiscardUnknown: true})(v)
+}
+
+// JSONDecoder returns a factory of protobuf JSON decoders that unmarshal with
+// the options.
+func JSONDecoder(opts protojson.UnmarshalOptions) httpc.DecodeFactory {
+	return func(v interface{}) httpc.DecodeFn {
+		return func(r io.Reader) error {
+			m, err := message(v)
+			if err != nil {
+				return err
+			}
+			b, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			return opts.Unmarshal(b, m)
+		}
+	}
+}
+
+// Register adds the protobuf decoder to the codecs, for the application/x-protobuf
+// and application/protobuf media types.
+func Register(codecs httpc.Codecs) httpc.Codecs {
+	codecs[ContentType] = Decode
+	codecs["application/protobuf"] = Decode
+	return codecs
+}
+
+// message returns the proto.Message of the target, allocating the message when
+// the target is a pointer to a nil message pointer.
+func message(v interface{}) (proto.Message, error) {
+	if m, ok := v.(proto.Message); ok {
+		return m, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || !rv.Elem().Type().Implements(messageType) {
+		return nil, fmt.Errorf("protobuf decoding requires a proto.Message: got %T", v)
+	}
+
+	elem := rv.Elem()
+	if elem.Kind() == reflect.Ptr && elem.IsNil() {
+		elem.Set(reflect.New(elem.Type().Elem()))
+	}
+	return elem.Interface().(proto.Message), nil
+}
@@ -0,0 +1,198 @@
+package protobuf_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/jsteenb2/httpc"
+	"github.com/jsteenb2/httpc/codec/protobuf"
+)
+
+func TestProtobuf(t *testing.T) {
+	t.Run("encode", func(t *testing.T) {
+		doer := doerFunc(func(r *http.Request) (*http.Response, error) {
+			equals(t, protobuf.ContentType, r.Header.Get("Content-Type"))
+			equals(t, protobuf.ContentType, r.Header.Get("Accept"))
+
+			b, err := ioutil.ReadAll(r.Body)
+			mustNoError(t, err)
+			var got wrapperspb.StringValue
+			mustNoError(t, proto.Unmarshal(b, &got))
+			equals(t, "payload", got.GetValue())
+			return stubResp(http.StatusOK, "", nil), nil
+		})
+
+		client := httpc.New(doer, httpc.WithEncoder(protobuf.Encoder()))
+
+		err := client.
+			Post("/foo").
+			Body(wrapperspb.String("payload")).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+	})
+
+	t.Run("encode requires a message", func(t *testing.T) {
+		doer := doerFunc(func(r *http.Request) (*http.Response, error) {
+			t.Fatal("unexpected request")
+			return nil, nil
+		})
+
+		client := httpc.New(doer, httpc.WithEncoder(protobuf.Encoder()))
+
+		err := client.Post("/foo").Body("payload").Do(context.TODO())
+		mustError(t, err)
+	})
+
+	t.Run("encode fn sets no headers", func(t *testing.T) {
+		doer := doerFunc(func(r *http.Request) (*http.Response, error) {
+			equals(t, "", r.Header.Get("Content-Type"))
+			equals(t, "", r.Header.Get("Accept"))
+			return stubResp(http.StatusOK, "", nil), nil
+		})
+
+		client := httpc.New(doer, httpc.WithEncoder(protobuf.Encode()))
+
+		err := client.
+			Post("/foo").
+			Body(wrapperspb.String("payload")).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+	})
+
+	t.Run("decode", func(t *testing.T) {
+		b, err := proto.Marshal(wrapperspb.Int64(42))
+		mustNoError(t, err)
+		client := httpc.New(responder(protobuf.ContentType, b))
+
+		var got wrapperspb.Int64Value
+		err = client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Decode(protobuf.Decode(&got)).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, int64(42), got.GetValue())
+	})
+
+	t.Run("typed decode", func(t *testing.T) {
+		b, err := proto.Marshal(wrapperspb.Bool(true))
+		mustNoError(t, err)
+		client := httpc.New(responder(protobuf.ContentType, b))
+
+		got, err := httpc.Do[*wrapperspb.BoolValue](context.TODO(), client.Get("/foo").Success(httpc.StatusOK()), protobuf.Decode)
+		mustNoError(t, err)
+
+		equals(t, true, got.GetValue())
+	})
+
+	t.Run("decode by content type", func(t *testing.T) {
+		b, err := proto.Marshal(wrapperspb.String("negotiated"))
+		mustNoError(t, err)
+		client := httpc.New(responder("application/x-protobuf", b),
+			httpc.WithCodecs(protobuf.Register(httpc.DefaultCodecs())),
+		)
+
+		var got wrapperspb.StringValue
+		err = client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			DecodeContent(&got).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, "negotiated", got.GetValue())
+	})
+
+	t.Run("json round trip", func(t *testing.T) {
+		doer := doerFunc(func(r *http.Request) (*http.Response, error) {
+			equals(t, protobuf.JSONContentType, r.Header.Get("Content-Type"))
+
+			b, err := ioutil.ReadAll(r.Body)
+			mustNoError(t, err)
+			equals(t, `"json"`, string(bytes.TrimSpace(b)))
+			return stubResp(http.StatusOK, protobuf.JSONContentType, []byte(`"echo"`)), nil
+		})
+
+		client := httpc.New(doer, httpc.WithEncoder(protobuf.JSONEncoder()))
+
+		var got wrapperspb.StringValue
+		err := client.
+			Put("/foo").
+			Body(wrapperspb.String("json")).
+			Success(httpc.StatusOK()).
+			Decode(protobuf.JSONDecode(&got)).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, "echo", got.GetValue())
+	})
+
+	t.Run("json decode error", func(t *testing.T) {
+		client := httpc.New(responder(protobuf.JSONContentType, []byte(`{"value":`)))
+
+		var got wrapperspb.StringValue
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Decode(protobuf.JSONDecode(&got)).
+			Do(context.TODO())
+		mustError(t, err)
+	})
+}
+
+type doerFunc func(*http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func responder(contentType string, body []byte) httpc.Doer {
+	return doerFunc(func(*http.Request) (*http.Response, error) {
+		return stubResp(http.StatusOK, contentType, body), nil
+	})
+}
+
+func stubResp(status int, contentType string, body []byte) *http.Response {
+	resp := &http.Response{
+		StatusCode: status,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+	}
+	if contentType != "" {
+		resp.Header.Set("Content-Type", contentType)
+	}
+	return resp
+}
+
+func equals(t *testing.T, expected interface{}, actual interface{}) {
+	t.Helper()
+	if expected == actual {
+		return
+	}
+	t.Errorf("expected: %v\tgot: %v", expected, actual)
+}
+
+func mustError(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		return
+	}
+	t.Fatal("expected error but none received")
+}
+
+func mustNoError(t *testing.T, err error) {
+	t.Helper()
+	if err == nil {
+		return
+	}
+	t.Fatal(err)
+}
@@ -0,0 +1,158 @@
+package httpc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jsteenb2/httpc"
+)
+
+func TestDecodeContent(t *testing.T) {
+	var gobBody bytes.Buffer
+	mustNoError(t, gob.NewEncoder(&gobBody).Encode(foo{Name: "gob"}))
+
+	decodeTests := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    string
+	}{
+		{
+			name:        "json",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"Name":"json"}`,
+			expected:    "json",
+		},
+		{
+			name:        "json suffix",
+			contentType: "application/problem+json",
+			body:        `{"Name":"problem"}`,
+			expected:    "problem",
+		},
+		{
+			name:        "xml",
+			contentType: "application/xml",
+			body:        `<foo><Name>xml</Name></foo>`,
+			expected:    "xml",
+		},
+		{
+			name:        "gob",
+			contentType: "application/x-gob",
+			body:        gobBody.String(),
+			expected:    "gob",
+		},
+		{
+			name:        "form",
+			contentType: httpc.FormContentType,
+			body:        "Name=form",
+			expected:    "form",
+		},
+	}
+
+	for _, tt := range decodeTests {
+		fn := func(t *testing.T) {
+			doer := newContentDoer(tt.contentType, tt.body)
+			client := httpc.New(doer)
+
+			var got foo
+			err := client.
+				Get("/foo").
+				Success(httpc.StatusOK()).
+				DecodeContent(&got).
+				Do(context.TODO())
+			mustNoError(t, err)
+
+			equals(t, tt.expected, got.Name)
+		}
+		t.Run(tt.name, fn)
+	}
+
+	t.Run("text", func(t *testing.T) {
+		client := httpc.New(newContentDoer("text/plain", "hello"))
+
+		var got string
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			DecodeContent(&got).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, "hello", got)
+	})
+
+	t.Run("unexpected media type", func(t *testing.T) {
+		client := httpc.New(newContentDoer("text/html", "<html>bad gateway</html>"))
+
+		var got foo
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			DecodeContent(&got).
+			Do(context.TODO())
+		mustError(t, err)
+
+		equals(t, true, errors.Is(err, httpc.ErrUnexpectedContentType))
+		equals(t, false, retryErr(err))
+		equals(t, true, strings.Contains(err.Error(), `\"text/html\"`))
+		equals(t, true, strings.Contains(err.Error(), "bad gateway"))
+	})
+
+	t.Run("missing content type", func(t *testing.T) {
+		client := httpc.New(newContentDoer("", `{"Name":"json"}`))
+
+		var got foo
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			DecodeContent(&got).
+			Do(context.TODO())
+		mustError(t, err)
+
+		equals(t, true, errors.Is(err, httpc.ErrUnexpectedContentType))
+	})
+
+	t.Run("custom codecs", func(t *testing.T) {
+		codecs := httpc.DefaultCodecs()
+		codecs["application/vnd.upper"] = func(v interface{}) httpc.DecodeFn {
+			return func(r io.Reader) error {
+				b, err := ioutil.ReadAll(r)
+				*(v.(*string)) = strings.ToUpper(string(b))
+				return err
+			}
+		}
+
+		client := httpc.New(newContentDoer("application/vnd.upper", "shout"), httpc.WithCodecs(codecs))
+
+		var got string
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			DecodeContent(&got).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, "SHOUT", got)
+	})
+}
+
+func newContentDoer(contentType, body string) *fakeDoer {
+	doer := new(fakeDoer)
+	doer.doFn = func(*http.Request) (*http.Response, error) {
+		resp := stubResp(http.StatusOK)
+		resp.Header = http.Header{}
+		if contentType != "" {
+			resp.Header.Set("Content-Type", contentType)
+		}
+		resp.Body = ioutil.NopCloser(strings.NewReader(body))
+		return resp, nil
+	}
+	return doer
+}
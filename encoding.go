@@ -4,18 +4,72 @@ import (
 	"bytes"
 	"encoding/gob"
 	"encoding/json"
+	"encoding/xml"
 	"io"
 )
 
 type (
-	// EncodeFn is an encoder func.
+	// EncodeFn is an encoder func. It is an Encoder that does not provide a
+	// media type.
 	EncodeFn func(interface{}) (io.Reader, error)
 
 	// DecodeFn is a decoder func.
 	DecodeFn func(r io.Reader) error
+
+	// Encoder encodes request bodies, providing the media type of the encoding
+	// that the request's Content-Type is set to.
+	Encoder interface {
+		Encode(v interface{}) (io.Reader, error)
+		ContentType() string
+	}
+
+	// Accepter is implemented by an Encoder that sets the Accept header of the
+	// requests it is used by, when the request does not set it.
+	Accepter interface {
+		Accept() string
+	}
 )
 
-// JSONEncode sets the client's encodeFn to a json encoder.
+// Encode calls the EncodeFn.
+func (fn EncodeFn) Encode(v interface{}) (io.Reader, error) {
+	return fn(v)
+}
+
+// ContentType returns an empty media type, leaving the Content-Type of the
+// request to be set by the caller.
+func (fn EncodeFn) ContentType() string {
+	return ""
+}
+
+// NewEncoder creates an Encoder from the encode func, setting the Content-Type
+// and, when not empty, the Accept header of the requests it is used by.
+func NewEncoder(fn EncodeFn, contentType, accept string) Encoder {
+	return encoder{
+		fn:          fn,
+		contentType: contentType,
+		accept:      accept,
+	}
+}
+
+type encoder struct {
+	fn                  EncodeFn
+	contentType, accept string
+}
+
+func (e encoder) Encode(v interface{}) (io.Reader, error) {
+	return e.fn(v)
+}
+
+func (e encoder) ContentType() string {
+	return e.contentType
+}
+
+func (e encoder) Accept() string {
+	return e.accept
+}
+
+// JSONEncode sets the client's encodeFn to a json encoder. It does not set the
+// Content-Type of the request, use JSONEncoder for an encoder that does.
 func JSONEncode() EncodeFn {
 	return func(v interface{}) (io.Reader, error) {
 		var buf bytes.Buffer
@@ -23,6 +77,12 @@ func JSONEncode() EncodeFn {
 	}
 }
 
+// JSONEncoder sets the client's encoder to a json encoder, with a Content-Type
+// and Accept of application/json.
+func JSONEncoder() Encoder {
+	return NewEncoder(JSONEncode(), "application/json", "application/json")
+}
+
 // JSONDecode sets the client's decodeFn to a json decoder.
 func JSONDecode(v interface{}) DecodeFn {
 	return func(r io.Reader) error {
@@ -30,7 +90,8 @@ func JSONDecode(v interface{}) DecodeFn {
 	}
 }
 
-// GobEncode sets the client's encodeFn to a gob encoder.
+// GobEncode sets the client's encodeFn to a gob encoder. It does not set the
+// Content-Type of the request, use GobEncoder for an encoder that does.
 func GobEncode() EncodeFn {
 	return func(v interface{}) (io.Reader, error) {
 		var buf bytes.Buffer
@@ -38,9 +99,130 @@ func GobEncode() EncodeFn {
 	}
 }
 
+// GobEncoder sets the client's encoder to a gob encoder, with a Content-Type and
+// Accept of application/x-gob.
+func GobEncoder() Encoder {
+	return NewEncoder(GobEncode(), "application/x-gob", "application/x-gob")
+}
+
 // GobDecode sets the client's decodeFn to a gob decoder.
 func GobDecode(v interface{}) DecodeFn {
 	return func(r io.Reader) error {
 		return gob.NewDecoder(r).Decode(v)
 	}
 }
+
+type xmlOpt struct {
+	header         bool
+	prefix, indent string
+	defaultSpace   string
+	charsetReader  func(charset string, input io.Reader) (io.Reader, error)
+	nonStrict      bool
+}
+
+// XMLOptFn is a optional parameter for the XML encoder and decoder.
+type XMLOptFn func(o xmlOpt) xmlOpt
+
+// XMLHeader writes the standard XML header, xml.Header, before the encoded body.
+func XMLHeader() XMLOptFn {
+	return func(o xmlOpt) xmlOpt {
+		o.header = true
+		return o
+	}
+}
+
+// XMLIndent indents the encoded body, with each element on a new line starting
+// with prefix followed by one or more copies of indent.
+func XMLIndent(prefix, indent string) XMLOptFn {
+	return func(o xmlOpt) xmlOpt {
+		o.prefix, o.indent = prefix, indent
+		return o
+	}
+}
+
+// XMLDefaultNamespace sets the namespace of decoded elements that have no
+// namespace, allowing struct tags with a namespace, i.e.
+// `xml:"http://example.com/ns item"`, to match documents that omit it.
+func XMLDefaultNamespace(ns string) XMLOptFn {
+	return func(o xmlOpt) xmlOpt {
+		o.defaultSpace = ns
+		return o
+	}
+}
+
+// XMLCharsetReader sets the func that converts documents with a non UTF-8
+// charset, as declared in the XML header, to UTF-8 when decoding.
+func XMLCharsetReader(fn func(charset string, input io.Reader) (io.Reader, error)) XMLOptFn {
+	return func(o xmlOpt) xmlOpt {
+		o.charsetReader = fn
+		return o
+	}
+}
+
+// XMLNonStrict decodes documents that do not strictly conform to the XML
+// specification, i.e. with unknown entities or unquoted attribute values.
+func XMLNonStrict() XMLOptFn {
+	return func(o xmlOpt) xmlOpt {
+		o.nonStrict = true
+		return o
+	}
+}
+
+func newXMLOpt(opts []XMLOptFn) xmlOpt {
+	var opt xmlOpt
+	for _, o := range opts {
+		opt = o(opt)
+	}
+	return opt
+}
+
+// XMLEncode sets the client's encodeFn to a xml encoder configured with the
+// options. It does not set the Content-Type of the request, use XMLEncoder for
+// an encoder that does.
+func XMLEncode(opts ...XMLOptFn) EncodeFn {
+	opt := newXMLOpt(opts)
+	return func(v interface{}) (io.Reader, error) {
+		var buf bytes.Buffer
+		if opt.header {
+			buf.WriteString(xml.Header)
+		}
+		enc := xml.NewEncoder(&buf)
+		enc.Indent(opt.prefix, opt.indent)
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+		return &buf, nil
+	}
+}
+
+// XMLEncoder sets the client's encoder to a xml encoder configured with the
+// options, with a Content-Type and Accept of application/xml.
+func XMLEncoder(opts ...XMLOptFn) Encoder {
+	return NewEncoder(XMLEncode(opts...), "application/xml", "application/xml")
+}
+
+// XMLDecode sets the client's decodeFn to a xml decoder. Elements are matched to
+// struct fields by the namespace and name of their struct tags, as defined by
+// encoding/xml.
+func XMLDecode(v interface{}) DecodeFn {
+	return XMLDecoder()(v)
+}
+
+// XMLDecoder returns a factory of xml decoders configured with the options, for
+// use in the client's Codecs or with the typed Do.
+func XMLDecoder(opts ...XMLOptFn) DecodeFactory {
+	opt := newXMLOpt(opts)
+	return func(v interface{}) DecodeFn {
+		return func(r io.Reader) error {
+			dec := xml.NewDecoder(r)
+			dec.DefaultSpace = opt.defaultSpace
+			dec.CharsetReader = opt.charsetReader
+			dec.Strict = !opt.nonStrict
+			if opt.nonStrict {
+				dec.AutoClose = xml.HTMLAutoClose
+				dec.Entity = xml.HTMLEntity
+			}
+			return dec.Decode(v)
+		}
+	}
+}
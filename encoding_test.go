@@ -0,0 +1,296 @@
+package httpc_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/jsteenb2/httpc"
+)
+
+func TestEncoder(t *testing.T) {
+	encoderTests := []struct {
+		name          string
+		encoder       httpc.Encoder
+		contentType   string
+		accept        string
+		clientOptions []httpc.ClientOptFn
+	}{
+		{
+			name:        "json",
+			encoder:     httpc.JSONEncoder(),
+			contentType: "application/json",
+			accept:      "application/json",
+		},
+		{
+			name:        "gob",
+			encoder:     httpc.GobEncoder(),
+			contentType: "application/x-gob",
+			accept:      "application/x-gob",
+		},
+		{
+			name:    "json encode fn",
+			encoder: httpc.JSONEncode(),
+		},
+		{
+			name:    "gob encode fn",
+			encoder: httpc.GobEncode(),
+		},
+		{
+			name:        "form",
+			encoder:     httpc.FormEncoder(),
+			contentType: httpc.FormContentType,
+		},
+		{
+			name:    "form encode fn",
+			encoder: httpc.FormEncode(),
+		},
+		{
+			name:          "client content type overrides encoder",
+			encoder:       httpc.JSONEncoder(),
+			contentType:   "application/vnd.api+json",
+			accept:        "text/plain",
+			clientOptions: []httpc.ClientOptFn{httpc.WithContentType("application/vnd.api+json"), httpc.WithHeader("Accept", "text/plain")},
+		},
+		{
+			name: "encode fn",
+			encoder: httpc.EncodeFn(func(v interface{}) (io.Reader, error) {
+				return bytes.NewBufferString("raw"), nil
+			}),
+			contentType:   "text/plain",
+			clientOptions: []httpc.ClientOptFn{httpc.WithContentType("text/plain")},
+		},
+		{
+			name: "custom encoder",
+			encoder: httpc.NewEncoder(func(v interface{}) (io.Reader, error) {
+				return bytes.NewBufferString("raw"), nil
+			}, "application/vnd.custom", "application/vnd.custom+json"),
+			contentType: "application/vnd.custom",
+			accept:      "application/vnd.custom+json",
+		},
+	}
+
+	for _, tt := range encoderTests {
+		fn := func(t *testing.T) {
+			doer := new(fakeDoer)
+			doer.doFn = func(r *http.Request) (*http.Response, error) {
+				equals(t, tt.contentType, r.Header.Get("Content-Type"))
+				equals(t, tt.accept, r.Header.Get("Accept"))
+				return stubResp(http.StatusOK), nil
+			}
+
+			opts := append([]httpc.ClientOptFn{httpc.WithEncoder(tt.encoder)}, tt.clientOptions...)
+			client := httpc.New(doer, opts...)
+
+			err := client.
+				Post("/foo").
+				Body(map[string]string{"name": "name"}).
+				Success(httpc.StatusOK()).
+				Do(context.TODO())
+			mustNoError(t, err)
+			equals(t, 1, doer.doCallCount)
+		}
+		t.Run(tt.name, fn)
+	}
+
+	t.Run("request content type overrides encoder", func(t *testing.T) {
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			equals(t, "application/vnd.api+json", r.Header.Get("Content-Type"))
+			return stubResp(http.StatusOK), nil
+		}
+
+		client := httpc.New(doer, httpc.WithEncoder(httpc.JSONEncoder()))
+
+		err := client.
+			Post("/foo").
+			ContentType("application/vnd.api+json").
+			Body(map[string]string{"name": "name"}).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+	})
+
+	t.Run("default client does not set accept", func(t *testing.T) {
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			if r.Method == http.MethodPost {
+				equals(t, "application/json", r.Header.Get("Content-Type"))
+			}
+			equals(t, "", r.Header.Get("Accept"))
+			return stubResp(http.StatusOK), nil
+		}
+
+		client := httpc.New(doer)
+
+		err := client.Get("/foo").Success(httpc.StatusOK()).Do(context.TODO())
+		mustNoError(t, err)
+
+		err = client.
+			Post("/foo").
+			Body(map[string]string{"name": "name"}).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+		equals(t, 2, doer.doCallCount)
+	})
+
+	t.Run("accept is set by the encoder without a body", func(t *testing.T) {
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			equals(t, "", r.Header.Get("Content-Type"))
+			equals(t, "application/json", r.Header.Get("Accept"))
+			return stubResp(http.StatusOK), nil
+		}
+
+		client := httpc.New(doer, httpc.WithEncoder(httpc.JSONEncoder()))
+
+		err := client.Get("/foo").Success(httpc.StatusOK()).Do(context.TODO())
+		mustNoError(t, err)
+	})
+}
+
+type xmlItem struct {
+	XMLName xml.Name `xml:"urn:example:items item"`
+	ID      int      `xml:"id,attr"`
+	Name    string   `xml:"urn:example:items name"`
+}
+
+func TestXML(t *testing.T) {
+	t.Run("encode", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			opts     []httpc.XMLOptFn
+			expected string
+		}{
+			{
+				name:     "without header",
+				expected: `<item xmlns="urn:example:items" id="1"><name xmlns="urn:example:items">widget</name></item>`,
+			},
+			{
+				name:     "with header",
+				opts:     []httpc.XMLOptFn{httpc.XMLHeader()},
+				expected: xml.Header + `<item xmlns="urn:example:items" id="1"><name xmlns="urn:example:items">widget</name></item>`,
+			},
+		}
+
+		for _, tt := range tests {
+			fn := func(t *testing.T) {
+				doer := new(fakeDoer)
+				doer.doFn = func(r *http.Request) (*http.Response, error) {
+					equals(t, "application/xml", r.Header.Get("Content-Type"))
+					equals(t, "application/xml", r.Header.Get("Accept"))
+					b, err := ioutil.ReadAll(r.Body)
+					mustNoError(t, err)
+					equals(t, tt.expected, string(b))
+					return stubResp(http.StatusOK), nil
+				}
+
+				client := httpc.New(doer, httpc.WithEncoder(httpc.XMLEncoder(tt.opts...)))
+
+				err := client.
+					Post("/items").
+					Body(xmlItem{ID: 1, Name: "widget"}).
+					Success(httpc.StatusOK()).
+					Do(context.TODO())
+				mustNoError(t, err)
+				equals(t, 1, doer.doCallCount)
+			}
+			t.Run(tt.name, fn)
+		}
+	})
+
+	t.Run("encode fn sets no headers", func(t *testing.T) {
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			equals(t, "", r.Header.Get("Content-Type"))
+			equals(t, "", r.Header.Get("Accept"))
+			b, err := ioutil.ReadAll(r.Body)
+			mustNoError(t, err)
+			equals(t, `<item xmlns="urn:example:items" id="1"><name xmlns="urn:example:items">widget</name></item>`, string(b))
+			return stubResp(http.StatusOK), nil
+		}
+
+		client := httpc.New(doer, httpc.WithEncoder(httpc.XMLEncode()))
+
+		err := client.
+			Post("/items").
+			Body(xmlItem{ID: 1, Name: "widget"}).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+		equals(t, 1, doer.doCallCount)
+	})
+
+	t.Run("decode namespaced", func(t *testing.T) {
+		body := `<?xml version="1.0" encoding="UTF-8"?>
+<i:item xmlns:i="urn:example:items" id="7"><i:name>gadget</i:name></i:item>`
+		client := httpc.New(newContentDoer("application/xml", body))
+
+		var got xmlItem
+		err := client.
+			Get("/items/7").
+			Success(httpc.StatusOK()).
+			Decode(httpc.XMLDecode(&got)).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, 7, got.ID)
+		equals(t, "gadget", got.Name)
+	})
+
+	t.Run("decode wrong namespace", func(t *testing.T) {
+		body := `<item xmlns="urn:example:other" id="7"><name>gadget</name></item>`
+		client := httpc.New(newContentDoer("application/xml", body))
+
+		var got xmlItem
+		err := client.
+			Get("/items/7").
+			Success(httpc.StatusOK()).
+			Decode(httpc.XMLDecode(&got)).
+			Do(context.TODO())
+		mustError(t, err)
+	})
+
+	t.Run("decode default namespace", func(t *testing.T) {
+		body := `<item id="7"><name>gadget</name></item>`
+		client := httpc.New(newContentDoer("application/xml", body))
+
+		var got xmlItem
+		err := client.
+			Get("/items/7").
+			Success(httpc.StatusOK()).
+			Decode(httpc.XMLDecoder(httpc.XMLDefaultNamespace("urn:example:items"))(&got)).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, "gadget", got.Name)
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			resp := stubResp(http.StatusOK)
+			resp.Body = r.Body
+			return resp, nil
+		}
+
+		client := httpc.New(doer, httpc.WithEncoder(httpc.XMLEncoder(httpc.XMLHeader(), httpc.XMLIndent("", "  "))))
+
+		var got xmlItem
+		err := client.
+			Put("/items/3").
+			Body(xmlItem{ID: 3, Name: "gizmo"}).
+			Success(httpc.StatusOK()).
+			Decode(httpc.XMLDecode(&got)).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, 3, got.ID)
+		equals(t, "gizmo", got.Name)
+	})
+}
@@ -5,7 +5,9 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type retrier interface {
@@ -16,16 +18,21 @@ type retrier interface {
 // both request and response bodies, status code of response and valid request
 // parameters.
 type HTTPErr struct {
-	caller     string
-	u          url.URL
-	method     string
-	errMsg     string
-	respBody   string
-	reqBody    string
-	statusCode int
-	retry      bool
-	notFound   bool
-	exists     bool
+	caller      string
+	err         error
+	u           url.URL
+	method      string
+	errMsg      string
+	respBody    string
+	reqBody     string
+	statusCode  int
+	retry       bool
+	notFound    bool
+	exists      bool
+	circuitOpen bool
+
+	retryAfter    time.Duration
+	hasRetryAfter bool
 }
 
 // NewClientErr is a constructor for a client error. The provided options
@@ -37,17 +44,23 @@ func NewClientErr(opts ...ErrOptFn) error {
 	}
 
 	newClientErr := &HTTPErr{
-		notFound: opt.notFound,
-		exists:   opt.exists,
-		retry:    opt.retry,
-		caller:   opt.caller,
-		errMsg:   "received unexpected response",
+		notFound:    opt.notFound,
+		exists:      opt.exists,
+		circuitOpen: opt.circuitOpen,
+		retry:       opt.retry,
+		caller:      opt.caller,
+		err:         opt.err,
+		errMsg:      "received unexpected response",
 	}
 	if opt.err != nil {
 		newClientErr.errMsg = opt.err.Error()
 	}
 
 	if opt.resp == nil {
+		if req := opt.req; req != nil {
+			newClientErr.u = *req.URL
+			newClientErr.method = req.Method
+		}
 		return newClientErr
 	}
 
@@ -62,6 +75,11 @@ func NewClientErr(opts ...ErrOptFn) error {
 		}
 	}
 	newClientErr.statusCode = opt.resp.StatusCode
+	now := time.Now
+	if opt.now != nil {
+		now = opt.now
+	}
+	newClientErr.retryAfter, newClientErr.hasRetryAfter = parseRetryAfter(opt.resp, now())
 
 	if body, err := ioutil.ReadAll(opt.resp.Body); err == nil {
 		newClientErr.respBody = string(body)
@@ -96,6 +114,18 @@ func (e *HTTPErr) BackoffMessage() string {
 	return e.errorBase()
 }
 
+// Unwrap returns the underlying error.
+func (e *HTTPErr) Unwrap() error {
+	return e.err
+}
+
+// RetryAfter provides the server directed delay before the next attempt, taken
+// from the response's Retry-After header, or the X-RateLimit-Reset header when
+// the rate limit is exhausted.
+func (e *HTTPErr) RetryAfter() (time.Duration, bool) {
+	return e.retryAfter, e.hasRetryAfter
+}
+
 // Retry provides the retry behavior.
 func (e *HTTPErr) Retry() bool {
 	return e.retry
@@ -111,6 +141,12 @@ func (e *HTTPErr) Exists() bool {
 	return e.exists
 }
 
+// CircuitOpen reports whether the request was short-circuited by an open
+// circuit breaker.
+func (e *HTTPErr) CircuitOpen() bool {
+	return e.circuitOpen
+}
+
 func (e *HTTPErr) errorBase() string {
 	var parts []string
 
@@ -137,12 +173,55 @@ func (e *HTTPErr) errorBase() string {
 	return strings.Join(parts, " ")
 }
 
+// parseRetryAfter parses the Retry-After header, as either delay seconds or an
+// http date, falling back to the X-RateLimit-Reset header, as either delay
+// seconds or a unix timestamp, when the rate limit is exhausted.
+func parseRetryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp.Header == nil {
+		return 0, false
+	}
+
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.ParseInt(v, 10, 64); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second, true
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return nonNegative(t.Sub(now)), true
+		}
+	}
+
+	exhausted := resp.StatusCode == http.StatusTooManyRequests || resp.Header.Get("X-RateLimit-Remaining") == "0"
+	if v := resp.Header.Get("X-RateLimit-Reset"); v != "" && exhausted {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		// values larger than a day of seconds are unix timestamps
+		if n > int64(24*time.Hour/time.Second) {
+			return nonNegative(time.Unix(n, 0).Sub(now)), true
+		}
+		return time.Duration(n) * time.Second, true
+	}
+
+	return 0, false
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
 type errOpt struct {
 	retry, notFound, exists bool
+	circuitOpen             bool
 
 	err    error
 	caller string
+	req    *http.Request
 	resp   *http.Response
+	now    func() time.Time
 }
 
 // ErrOptFn is a optional parameter that allows one to extend a client error.
@@ -169,6 +248,15 @@ func Resp(resp *http.Response) ErrOptFn {
 	}
 }
 
+// Req sets the request the error occurred on. The response's request takes
+// precedence when a response is provided.
+func Req(req *http.Request) ErrOptFn {
+	return func(o errOpt) errOpt {
+		o.req = req
+		return o
+	}
+}
+
 // Retry sets the option and subsequent client error to retriable, retry=true.
 func Retry() ErrOptFn {
 	return func(o errOpt) errOpt {
@@ -192,3 +280,20 @@ func Exists() ErrOptFn {
 		return o
 	}
 }
+
+// CircuitOpen sets the client error to CircuitOpen, circuitOpen=true.
+func CircuitOpen() ErrOptFn {
+	return func(o errOpt) errOpt {
+		o.circuitOpen = true
+		return o
+	}
+}
+
+// errNow sets the func used to obtain the time server directed delays, i.e. a
+// Retry-After http date, are relative to.
+func errNow(fn func() time.Time) ErrOptFn {
+	return func(o errOpt) errOpt {
+		o.now = fn
+		return o
+	}
+}
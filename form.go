@@ -0,0 +1,449 @@
+package httpc
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormContentType is the Content-Type of url encoded form bodies.
+const FormContentType = "application/x-www-form-urlencoded"
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+)
+
+// FormEncode sets the client's encodeFn to a url encoded form encoder. It
+// encodes url.Values, maps with string keys and structs. Struct fields are keyed
+// by their `form` tag, falling back to the field name, and can be skipped with a
+// tag of "-" or when empty with the omitempty option, i.e.
+// `form:"name,omitempty"`. Slices are encoded as repeated keys, nested structs
+// and maps as bracketed keys, i.e. addr[city]. It does not set the Content-Type
+// of the request, use FormEncoder for an encoder that does.
+func FormEncode() EncodeFn {
+	return func(v interface{}) (io.Reader, error) {
+		values, err := formValues(v)
+		if err != nil {
+			return nil, err
+		}
+		return strings.NewReader(values.Encode()), nil
+	}
+}
+
+// FormEncoder sets the client's encoder to a url encoded form encoder, with a
+// Content-Type of application/x-www-form-urlencoded.
+func FormEncoder() Encoder {
+	return NewEncoder(FormEncode(), FormContentType, "")
+}
+
+// FormDecode sets the client's decodeFn to a url encoded form decoder. It
+// decodes into a *url.Values, a map with string keys and string or []string
+// values, or a struct, following the same rules as FormEncode.
+func FormDecode(v interface{}) DecodeFn {
+	return func(r io.Reader) error {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		values, err := url.ParseQuery(string(b))
+		if err != nil {
+			return err
+		}
+		return decodeForm(values, v)
+	}
+}
+
+func formValues(v interface{}) (url.Values, error) {
+	if values, ok := v.(url.Values); ok {
+		return values, nil
+	}
+
+	values := make(url.Values)
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return values, nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map, reflect.Struct:
+		return values, encodeFormValue(values, "", rv, false)
+	default:
+		return nil, fmt.Errorf("form encoding requires url.Values, a map or a struct: got %T", v)
+	}
+}
+
+func encodeFormValue(values url.Values, key string, rv reflect.Value, omitEmpty bool) error {
+	if omitEmpty && isEmptyFormValue(rv) {
+		return nil
+	}
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	if s, ok, err := formScalar(rv); ok || err != nil {
+		if err == nil {
+			values.Add(key, s)
+		}
+		return err
+	}
+
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := encodeFormValue(values, key, rv.Index(i), false); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("form encoding requires maps with string keys: got %s", rv.Type())
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			if err := encodeFormValue(values, formKey(key, iter.Key().String()), iter.Value(), false); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Struct:
+		return encodeFormStruct(values, key, rv)
+	default:
+		return fmt.Errorf("form encoding unsupported type for %q: %s", key, rv.Type())
+	}
+}
+
+func encodeFormStruct(values url.Values, key string, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name, omitEmpty, ok := formField(field)
+		if !ok {
+			continue
+		}
+
+		fieldKey := formKey(key, name)
+		if name == "" {
+			fieldKey = key
+		}
+		if err := encodeFormValue(values, fieldKey, rv.Field(i), omitEmpty); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formScalar returns the form value of scalars, reporting false when the value
+// is not a scalar.
+func formScalar(rv reflect.Value) (string, bool, error) {
+	if rv.Type().Implements(textMarshalerType) {
+		b, err := rv.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), true, err
+	}
+	if rv.Type() == timeType {
+		return rv.Interface().(time.Time).Format(time.RFC3339Nano), true, nil
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), true, nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), true, nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, rv.Type().Bits()), true, nil
+	}
+	return "", false, nil
+}
+
+func decodeForm(values url.Values, v interface{}) error {
+	if vals, ok := v.(*url.Values); ok {
+		*vals = values
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.Map:
+		return decodeFormMap(values, rv)
+	case rv.Kind() != reflect.Ptr || rv.IsNil():
+		return errors.New("form decoding requires a non nil pointer or map")
+	}
+	rv = rv.Elem()
+
+	switch rv.Kind() {
+	case reflect.Map:
+		return decodeFormMap(values, rv)
+	case reflect.Struct:
+		return decodeFormStruct(values, "", rv)
+	default:
+		return fmt.Errorf("form decoding unsupported type: %T", v)
+	}
+}
+
+func decodeFormMap(values url.Values, rv reflect.Value) error {
+	rt := rv.Type()
+	if rt.Key().Kind() != reflect.String {
+		return fmt.Errorf("form decoding requires maps with string keys: got %s", rt)
+	}
+	if rv.IsNil() {
+		if !rv.CanSet() {
+			return errors.New("form decoding into nil map")
+		}
+		rv.Set(reflect.MakeMap(rt))
+	}
+
+	for k, vals := range values {
+		elem := reflect.New(rt.Elem()).Elem()
+		if err := setFormValue(elem, vals); err != nil {
+			return fmt.Errorf("form decoding %q: %w", k, err)
+		}
+		rv.SetMapIndex(reflect.ValueOf(k).Convert(rt.Key()), elem)
+	}
+	return nil
+}
+
+func decodeFormStruct(values url.Values, key string, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name, _, ok := formField(field)
+		if !ok {
+			continue
+		}
+
+		fieldKey := formKey(key, name)
+		if name == "" {
+			fieldKey = key
+		}
+
+		fv := rv.Field(i)
+		if isFormNested(fv.Type()) {
+			if !hasFormPrefix(values, fieldKey) {
+				continue
+			}
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() && !fv.CanSet() {
+					continue
+				}
+				if fv.IsNil() {
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+			if err := decodeFormStruct(values, fieldKey, fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if fv.Kind() == reflect.Map {
+			nested := nestedFormValues(values, fieldKey)
+			if len(nested) == 0 {
+				continue
+			}
+			if err := decodeFormMap(nested, fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		vals, ok := values[fieldKey]
+		if !ok {
+			continue
+		}
+		if err := setFormValue(fv, vals); err != nil {
+			return fmt.Errorf("form decoding %q: %w", fieldKey, err)
+		}
+	}
+	return nil
+}
+
+func setFormValue(rv reflect.Value, vals []string) error {
+	if len(vals) == 0 {
+		return nil
+	}
+
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return setFormValue(rv.Elem(), vals)
+	}
+
+	if rv.CanAddr() && rv.Addr().Type().Implements(textUnmarshalerType) {
+		return rv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(vals[0]))
+	}
+	if rv.Type() == timeType {
+		t, err := time.Parse(time.RFC3339Nano, vals[0])
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Slice:
+		slice := reflect.MakeSlice(rv.Type(), len(vals), len(vals))
+		for i, s := range vals {
+			if err := setFormValue(slice.Index(i), []string{s}); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+		return nil
+	case reflect.String:
+		rv.SetString(vals[0])
+	case reflect.Bool:
+		b, err := strconv.ParseBool(vals[0])
+		if err != nil {
+			return err
+		}
+		rv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(vals[0], 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(vals[0], 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(vals[0], rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(f)
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			return fmt.Errorf("unsupported type: %s", rv.Type())
+		}
+		if len(vals) == 1 {
+			rv.Set(reflect.ValueOf(vals[0]))
+			return nil
+		}
+		rv.Set(reflect.ValueOf(vals))
+	default:
+		return fmt.Errorf("unsupported type: %s", rv.Type())
+	}
+	return nil
+}
+
+// formField returns the form key of the struct field, reporting false when the
+// field is skipped.
+func formField(field reflect.StructField) (string, bool, bool) {
+	embedded := field.Anonymous && isFormNested(field.Type)
+	if field.PkgPath != "" && !embedded {
+		return "", false, false
+	}
+
+	tag := field.Tag.Get("form")
+	if tag == "-" {
+		return "", false, false
+	}
+
+	name, opts := tag, ""
+	if i := strings.Index(tag, ","); i >= 0 {
+		name, opts = tag[:i], tag[i+1:]
+	}
+	omitEmpty := false
+	for _, o := range strings.Split(opts, ",") {
+		if o == "omitempty" {
+			omitEmpty = true
+		}
+	}
+
+	if name == "" && !embedded {
+		name = field.Name
+	}
+	return name, omitEmpty, true
+}
+
+// isEmptyFormValue reports whether the value is empty, as defined by the
+// omitempty option of encoding/json, with the addition of zero structs, i.e. a
+// zero time.Time.
+func isEmptyFormValue(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Struct:
+		return rv.IsZero()
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return rv.IsNil()
+	}
+	return false
+}
+
+func formKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "[" + name + "]"
+}
+
+// isFormNested reports whether the type is decoded from bracketed keys.
+func isFormNested(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return false
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// nestedFormValues returns the values keyed by key[name], keyed by name.
+func nestedFormValues(values url.Values, key string) url.Values {
+	nested := make(url.Values)
+	prefix := key + "["
+	for k, vals := range values {
+		if !strings.HasPrefix(k, prefix) || !strings.HasSuffix(k, "]") {
+			continue
+		}
+		nested[k[len(prefix):len(k)-1]] = vals
+	}
+	return nested
+}
+
+func hasFormPrefix(values url.Values, key string) bool {
+	if key == "" {
+		return true
+	}
+	for k := range values {
+		if strings.HasPrefix(k, key+"[") {
+			return true
+		}
+	}
+	return false
+}
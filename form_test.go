@@ -0,0 +1,170 @@
+package httpc_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/httpc"
+)
+
+type formAddr struct {
+	City string `form:"city"`
+	Zip  string `form:"zip,omitempty"`
+}
+
+type formBody struct {
+	Name    string            `form:"name"`
+	Age     int               `form:"age,omitempty"`
+	Admin   bool              `form:"admin"`
+	Tags    []string          `form:"tags"`
+	Addr    formAddr          `form:"addr"`
+	Meta    map[string]string `form:"meta,omitempty"`
+	Created time.Time         `form:"created,omitempty"`
+	Secret  string            `form:"-"`
+	Plain   string
+}
+
+func TestFormEncode(t *testing.T) {
+	encodeTests := []struct {
+		name     string
+		input    interface{}
+		expected string
+	}{
+		{
+			name:     "url values",
+			input:    url.Values{"b": {"2"}, "a": {"1", "3"}},
+			expected: "a=1&a=3&b=2",
+		},
+		{
+			name:     "map",
+			input:    map[string]interface{}{"a": 1, "b": []string{"x", "y"}},
+			expected: "a=1&b=x&b=y",
+		},
+		{
+			name: "struct",
+			input: formBody{
+				Name:   "name",
+				Tags:   []string{"a", "b"},
+				Addr:   formAddr{City: "nyc"},
+				Meta:   map[string]string{"k": "v"},
+				Secret: "secret",
+				Plain:  "plain",
+			},
+			expected: "Plain=plain&addr%5Bcity%5D=nyc&admin=false&meta%5Bk%5D=v&name=name&tags=a&tags=b",
+		},
+		{
+			name:     "struct pointer omits empty",
+			input:    &formBody{Name: "name", Age: 3},
+			expected: "Plain=&addr%5Bcity%5D=&admin=false&age=3&name=name",
+		},
+	}
+
+	for _, tt := range encodeTests {
+		fn := func(t *testing.T) {
+			doer := new(fakeDoer)
+			doer.doFn = func(r *http.Request) (*http.Response, error) {
+				equals(t, httpc.FormContentType, r.Header.Get("Content-Type"))
+				b, err := ioutil.ReadAll(r.Body)
+				mustNoError(t, err)
+				equals(t, tt.expected, string(b))
+				return stubResp(http.StatusOK), nil
+			}
+
+			client := httpc.New(doer, httpc.WithEncoder(httpc.FormEncoder()))
+
+			err := client.
+				Post("/foo").
+				Body(tt.input).
+				Success(httpc.StatusOK()).
+				Do(context.TODO())
+			mustNoError(t, err)
+			equals(t, 1, doer.doCallCount)
+		}
+		t.Run(tt.name, fn)
+	}
+
+	t.Run("unsupported type", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusOK)
+		client := httpc.New(doer, httpc.WithEncoder(httpc.FormEncoder()))
+
+		err := client.Post("/foo").Body("raw").Do(context.TODO())
+		mustError(t, err)
+		equals(t, 0, doer.doCallCount)
+	})
+}
+
+func TestFormDecode(t *testing.T) {
+	body := "name=name&age=3&admin=true&tags=a&tags=b&addr%5Bcity%5D=nyc&meta%5Bk%5D=v&created=2020-01-02T03%3A04%3A05Z&Plain=plain&Secret=x"
+
+	newClient := func() *httpc.Client {
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			resp := stubResp(http.StatusOK)
+			resp.Body = ioutil.NopCloser(strings.NewReader(body))
+			return resp, nil
+		}
+		return httpc.New(doer)
+	}
+
+	t.Run("struct", func(t *testing.T) {
+		var got formBody
+		err := newClient().
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Decode(httpc.FormDecode(&got)).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, "name", got.Name)
+		equals(t, 3, got.Age)
+		equals(t, true, got.Admin)
+		mustEquals(t, 2, len(got.Tags))
+		equals(t, "b", got.Tags[1])
+		equals(t, "nyc", got.Addr.City)
+		equals(t, "v", got.Meta["k"])
+		equals(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), got.Created)
+		equals(t, "plain", got.Plain)
+		equals(t, "", got.Secret)
+	})
+
+	t.Run("url values", func(t *testing.T) {
+		var got url.Values
+		err := newClient().
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Decode(httpc.FormDecode(&got)).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, "a", got.Get("tags"))
+	})
+
+	t.Run("map", func(t *testing.T) {
+		got := make(map[string][]string)
+		err := newClient().
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Decode(httpc.FormDecode(got)).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, 2, len(got["tags"]))
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		var got struct {
+			Age int `form:"name"`
+		}
+		err := newClient().
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Decode(httpc.FormDecode(&got)).
+			Do(context.TODO())
+		mustError(t, err)
+	})
+}
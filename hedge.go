@@ -0,0 +1,299 @@
+package httpc
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// hedgeMinSamples is the number of latencies observed before the percentile
+// delay replaces the fixed delay.
+const hedgeMinSamples = 10
+
+type hedgeOpt struct {
+	delay      time.Duration
+	percentile float64
+	window     int
+	methods    map[string]bool
+}
+
+// HedgeOptFn is a optional parameter for a Hedger.
+type HedgeOptFn func(o hedgeOpt) hedgeOpt
+
+// HedgeDelay sets the fixed delay after which a hedged attempt is sent. When a
+// percentile is set, the fixed delay is used until enough latencies are
+// observed. Defaults to 100ms.
+func HedgeDelay(d time.Duration) HedgeOptFn {
+	return func(o hedgeOpt) hedgeOpt {
+		o.delay = d
+		return o
+	}
+}
+
+// HedgePercentile derives the delay from the percentile, i.e. 0.95, of the
+// latencies of the last window attempts that succeeded or lost the race to one
+// that did. The latency of a losing attempt is the time until it was cancelled.
+func HedgePercentile(p float64, window int) HedgeOptFn {
+	return func(o hedgeOpt) hedgeOpt {
+		o.percentile = p
+		o.window = window
+		return o
+	}
+}
+
+// HedgeMethods sets the idempotent methods that are hedged. Defaults to GET,
+// HEAD and OPTIONS.
+func HedgeMethods(methods ...string) HedgeOptFn {
+	return func(o hedgeOpt) hedgeOpt {
+		o.methods = make(map[string]bool, len(methods))
+		for _, m := range methods {
+			o.methods[m] = true
+		}
+		return o
+	}
+}
+
+// HedgeStats are the metrics of a Hedger.
+type HedgeStats struct {
+	// Requests is the number of attempts eligible for hedging.
+	Requests int64
+	// Hedged is the number of attempts a hedged attempt was sent for.
+	Hedged int64
+	// HedgeWins is the number of attempts won by the hedged attempt.
+	HedgeWins int64
+}
+
+// Hedger provides tail latency protection for idempotent requests. When an
+// attempt has not completed after the hedge delay, a second identical attempt
+// is sent, and whichever succeeds first is used. The loser is cancelled via its
+// context. The hedged attempt is subject to the request's rate limiter and
+// circuit breaker, and is not sent when the limiter has no capacity for it
+// without waiting or the circuit does not allow it. Requests with a body read
+// from a reader, i.e. a BodyReader or a Multipart part, are not hedged, as the
+// attempts would read the one reader at the same time. A Hedger is safe for
+// concurrent use.
+type Hedger struct {
+	opt hedgeOpt
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+	stats     HedgeStats
+}
+
+// NewHedger creates a Hedger.
+func NewHedger(opts ...HedgeOptFn) *Hedger {
+	opt := hedgeOpt{
+		delay: 100 * time.Millisecond,
+		methods: map[string]bool{
+			http.MethodGet:     true,
+			http.MethodHead:    true,
+			http.MethodOptions: true,
+		},
+	}
+	for _, o := range opts {
+		opt = o(opt)
+	}
+
+	return &Hedger{opt: opt}
+}
+
+// Stats returns the metrics of the hedger.
+func (h *Hedger) Stats() HedgeStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stats
+}
+
+func (h *Hedger) applies(req *http.Request) bool {
+	if !h.opt.methods[req.Method] {
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// hedgeHooks are the hooks of the request a hedger sends the attempts of.
+type hedgeHooks struct {
+	// done records the outcome of the first attempt.
+	done func(*http.Response, error)
+	// admit admits a hedged attempt, returning the func that records its
+	// outcome, or false when the attempt must not be sent.
+	admit func(*http.Request) (func(*http.Response, error), bool)
+	// succeeded reports whether the response of an attempt is a success.
+	succeeded func(*http.Response) bool
+}
+
+type hedgeResult struct {
+	idx     int
+	resp    *http.Response
+	err     error
+	hedge   bool
+	latency time.Duration
+	cancel  context.CancelFunc
+}
+
+// do sends the request, hedging it with a second attempt after the hedge delay.
+// The first response that succeeds is returned, the body of the response that
+// is returned cancels its attempt's context when closed.
+func (h *Hedger) do(doer Doer, req *http.Request, hooks hedgeHooks) (*http.Response, error) {
+	h.mu.Lock()
+	h.stats.Requests++
+	h.mu.Unlock()
+
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	send := func(hedge bool) bool {
+		ctx, cancel := context.WithCancel(req.Context())
+		attemptReq := req.Clone(ctx)
+		done := hooks.done
+		if hedge {
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					cancel()
+					return false
+				}
+				attemptReq.Body = body
+			}
+			var ok bool
+			if done, ok = hooks.admit(attemptReq); !ok {
+				cancel()
+				return false
+			}
+		}
+		idx := len(cancels)
+		cancels = append(cancels, cancel)
+
+		go func() {
+			start := time.Now()
+			resp, err := doer.Do(attemptReq)
+			latency := time.Since(start)
+			done(resp, err)
+			results <- hedgeResult{
+				idx:     idx,
+				resp:    resp,
+				err:     err,
+				hedge:   hedge,
+				latency: latency,
+				cancel:  cancel,
+			}
+		}()
+		return true
+	}
+
+	send(false)
+	inflight := 1
+
+	timer := time.NewTimer(h.delay())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			if !send(true) {
+				continue
+			}
+			inflight++
+			h.mu.Lock()
+			h.stats.Hedged++
+			h.mu.Unlock()
+		case res := <-results:
+			inflight--
+			won := res.err == nil && hooks.succeeded(res.resp)
+			if !won && inflight > 0 {
+				res.release()
+				continue
+			}
+
+			if won {
+				h.observe(res.latency)
+				if res.hedge {
+					h.mu.Lock()
+					h.stats.HedgeWins++
+					h.mu.Unlock()
+				}
+			}
+			for i, cancel := range cancels {
+				if i != res.idx {
+					cancel()
+				}
+			}
+			go func(n int, won bool) {
+				for ; n > 0; n-- {
+					r := <-results
+					if won {
+						h.observe(r.latency)
+					}
+					r.release()
+				}
+			}(inflight, won)
+
+			if res.resp != nil {
+				res.resp.Body = &cancelReadCloser{ReadCloser: res.resp.Body, cancel: res.cancel}
+			} else {
+				res.cancel()
+			}
+			return res.resp, res.err
+		}
+	}
+}
+
+func (h *Hedger) delay() time.Duration {
+	if h.opt.percentile <= 0 {
+		return h.opt.delay
+	}
+
+	h.mu.Lock()
+	latencies := append([]time.Duration(nil), h.latencies...)
+	h.mu.Unlock()
+
+	if len(latencies) < hedgeMinSamples {
+		return h.opt.delay
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	idx := int(math.Ceil(h.opt.percentile*float64(len(latencies)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return latencies[idx]
+}
+
+// observe records the latency of an attempt in the window the delay is derived
+// from.
+func (h *Hedger) observe(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.opt.percentile <= 0 || h.opt.window <= 0 {
+		return
+	}
+	if len(h.latencies) < h.opt.window {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+	h.latencies[h.next] = latency
+	h.next = (h.next + 1) % h.opt.window
+}
+
+// release closes the response of an attempt that lost the race.
+func (r hedgeResult) release() {
+	r.cancel()
+	if r.resp != nil {
+		drain(r.resp.Body)
+	}
+}
+
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelReadCloser) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
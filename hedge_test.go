@@ -0,0 +1,273 @@
+package httpc_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/httpc"
+)
+
+func TestHedger(t *testing.T) {
+	t.Run("hedged attempt wins over slow primary", func(t *testing.T) {
+		var calls int32
+		primaryCancelled := make(chan struct{})
+		doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				<-r.Context().Done()
+				close(primaryCancelled)
+				return nil, r.Context().Err()
+			}
+			return stubResp(http.StatusOK), nil
+		})
+
+		hedger := httpc.NewHedger(httpc.HedgeDelay(5 * time.Millisecond))
+		client := httpc.New(doer, httpc.WithHedger(hedger))
+
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		select {
+		case <-primaryCancelled:
+		case <-time.After(time.Second):
+			t.Fatal("expected primary attempt to be cancelled")
+		}
+
+		equals(t, int32(2), atomic.LoadInt32(&calls))
+		stats := hedger.Stats()
+		equals(t, int64(1), stats.Requests)
+		equals(t, int64(1), stats.Hedged)
+		equals(t, int64(1), stats.HedgeWins)
+	})
+
+	t.Run("fast primary is not hedged", func(t *testing.T) {
+		var calls int32
+		doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&calls, 1)
+			return stubResp(http.StatusOK), nil
+		})
+
+		hedger := httpc.NewHedger(httpc.HedgeDelay(time.Second))
+		client := httpc.New(doer, httpc.WithHedger(hedger))
+
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, int32(1), atomic.LoadInt32(&calls))
+		equals(t, int64(0), hedger.Stats().Hedged)
+	})
+
+	t.Run("primary wins when hedge fails", func(t *testing.T) {
+		var calls int32
+		doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				time.Sleep(20 * time.Millisecond)
+				return stubResp(http.StatusOK), nil
+			}
+			return stubResp(http.StatusInternalServerError), nil
+		})
+
+		hedger := httpc.NewHedger(httpc.HedgeDelay(5 * time.Millisecond))
+		client := httpc.New(doer, httpc.WithHedger(hedger))
+
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		stats := hedger.Stats()
+		equals(t, int64(1), stats.Hedged)
+		equals(t, int64(0), stats.HedgeWins)
+	})
+
+	t.Run("non idempotent methods are not hedged", func(t *testing.T) {
+		var calls int32
+		doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(20 * time.Millisecond)
+			return stubResp(http.StatusOK), nil
+		})
+
+		hedger := httpc.NewHedger(httpc.HedgeDelay(time.Millisecond))
+		client := httpc.New(doer, httpc.WithHedger(hedger))
+
+		err := client.
+			Post("/foo").
+			Body(foo{Name: "name"}).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, int32(1), atomic.LoadInt32(&calls))
+		equals(t, int64(0), hedger.Stats().Requests)
+	})
+
+	t.Run("request hedger overrides client", func(t *testing.T) {
+		var calls int32
+		doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				<-r.Context().Done()
+				return nil, r.Context().Err()
+			}
+			return stubResp(http.StatusOK), nil
+		})
+
+		hedger := httpc.NewHedger(
+			httpc.HedgeDelay(time.Millisecond),
+			httpc.HedgeMethods(http.MethodGet, http.MethodPut),
+		)
+		client := httpc.New(doer, httpc.WithEncoder(httpc.JSONEncode()))
+
+		err := client.
+			Put("/foo").
+			Body(foo{Name: "name"}).
+			Hedge(hedger).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, int64(1), hedger.Stats().HedgeWins)
+	})
+
+	t.Run("hedged attempt is not sent past the rate limiter", func(t *testing.T) {
+		var calls int32
+		doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(20 * time.Millisecond)
+			return stubResp(http.StatusOK), nil
+		})
+
+		hedger := httpc.NewHedger(httpc.HedgeDelay(time.Millisecond))
+		client := httpc.New(doer,
+			httpc.WithHedger(hedger),
+			httpc.WithRateLimiter(httpc.NewRateLimiter(httpc.RateLimitGlobal(1, 1))),
+		)
+
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, int32(1), atomic.LoadInt32(&calls))
+		equals(t, int64(0), hedger.Stats().Hedged)
+	})
+
+	t.Run("hedged attempt is not sent past the circuit breaker", func(t *testing.T) {
+		var calls int32
+		doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				return stubResp(http.StatusInternalServerError), nil
+			}
+			time.Sleep(20 * time.Millisecond)
+			return stubResp(http.StatusOK), nil
+		})
+
+		cb := httpc.NewCircuitBreaker(
+			httpc.BreakerConsecutiveFailures(1),
+			httpc.BreakerCooldown(time.Millisecond),
+		)
+		hedger := httpc.NewHedger(httpc.HedgeDelay(time.Millisecond))
+		client := httpc.New(doer, httpc.WithHedger(hedger), httpc.WithCircuitBreaker(cb))
+
+		mustError(t, client.Get("http://example.com/foo").Success(httpc.StatusOK()).Do(context.TODO()))
+		time.Sleep(5 * time.Millisecond)
+
+		err := client.
+			Get("http://example.com/foo").
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, int32(2), atomic.LoadInt32(&calls))
+		equals(t, int64(0), hedger.Stats().Hedged)
+		equals(t, httpc.BreakerClosed, cb.State("example.com"))
+	})
+
+	t.Run("streamed bodies are not hedged", func(t *testing.T) {
+		var (
+			mu    sync.Mutex
+			sizes []int
+		)
+		doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+			time.Sleep(20 * time.Millisecond)
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			sizes = append(sizes, len(b))
+			mu.Unlock()
+			return stubResp(http.StatusOK), nil
+		})
+
+		hedger := httpc.NewHedger(httpc.HedgeDelay(time.Millisecond), httpc.HedgeMethods(http.MethodPut))
+		client := httpc.New(doer, httpc.WithHedger(hedger))
+
+		body := bytes.NewReader(make([]byte, 512<<10))
+		err := client.
+			Put("/foo").
+			BodyReader(body, body.Size()).
+			BodyRewind(func() (io.Reader, error) {
+				_, err := body.Seek(0, io.SeekStart)
+				return body, err
+			}).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		mu.Lock()
+		defer mu.Unlock()
+		mustEquals(t, 1, len(sizes))
+		equals(t, 512<<10, sizes[0])
+		equals(t, int64(0), hedger.Stats().Hedged)
+	})
+
+	t.Run("percentile delay", func(t *testing.T) {
+		var blocked int32
+		doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(r.URL.Path, "/slow") && atomic.CompareAndSwapInt32(&blocked, 0, 1) {
+				<-r.Context().Done()
+				return nil, r.Context().Err()
+			}
+			return stubResp(http.StatusOK), nil
+		})
+
+		hedger := httpc.NewHedger(
+			httpc.HedgeDelay(time.Hour),
+			httpc.HedgePercentile(0.9, 20),
+		)
+		client := httpc.New(doer, httpc.WithHedger(hedger))
+
+		for i := 0; i < 10; i++ {
+			mustNoError(t, client.Get("/fast").Success(httpc.StatusOK()).Do(context.TODO()))
+		}
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- client.Get("/slow").Success(httpc.StatusOK()).Do(context.TODO())
+		}()
+
+		select {
+		case err := <-errs:
+			mustNoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("expected the observed latencies to set the hedge delay")
+		}
+		equals(t, int64(1), hedger.Stats().Hedged)
+	})
+}
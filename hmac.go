@@ -0,0 +1,289 @@
+package httpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HMACComponent is a part of the request that is included in the HMAC
+// signature.
+type HMACComponent int
+
+// HMAC signature components. Each component is written on its own line of the
+// string to sign, in the order the components are provided.
+const (
+	// HMACMethod is the http method, i.e. GET.
+	HMACMethod HMACComponent = iota
+	// HMACHost is the host of the request.
+	HMACHost
+	// HMACPath is the escaped path of the request url.
+	HMACPath
+	// HMACQuery is the query params sorted by key and then value.
+	HMACQuery
+	// HMACBodyDigest is the hex encoded digest of the body, using the hash of
+	// the signer.
+	HMACBodyDigest
+	// HMACTimestamp is the unix timestamp in seconds set on the timestamp header.
+	// The timestamp header is only set when the component is included.
+	HMACTimestamp
+	// HMACNonce is the random nonce set on the nonce header. The nonce header is
+	// only set when the component is included.
+	HMACNonce
+	// HMACHeaders is the lowercase name and value of each header added with
+	// HMACSignHeaders, one per line.
+	HMACHeaders
+)
+
+type hmacOpt struct {
+	newHash         func() hash.Hash
+	components      []HMACComponent
+	signatureHeader string
+	keyID           string
+	keyIDHeader     string
+	timestampHeader string
+	nonceHeader     string
+	digestHeader    string
+	signHeaders     []string
+	hexEncode       bool
+	now             func() time.Time
+	err             error
+}
+
+// HMACOptFn is a optional parameter for the HMACAuth Authorizer.
+type HMACOptFn func(o hmacOpt) hmacOpt
+
+// HMACHash sets the hash used for the signature and body digest. Defaults to sha256.
+func HMACHash(newHash func() hash.Hash) HMACOptFn {
+	return func(o hmacOpt) hmacOpt {
+		o.newHash = newHash
+		return o
+	}
+}
+
+// HMACComponents sets the components, and their order, included in the
+// signature. Defaults to method, path, query, timestamp and body digest.
+func HMACComponents(components ...HMACComponent) HMACOptFn {
+	return func(o hmacOpt) hmacOpt {
+		o.components = components
+		return o
+	}
+}
+
+// HMACSignatureHeader sets the header the signature is written to. Defaults to
+// X-Signature.
+func HMACSignatureHeader(name string) HMACOptFn {
+	return func(o hmacOpt) hmacOpt {
+		o.signatureHeader = name
+		return o.checkHeader("HMACSignatureHeader", name)
+	}
+}
+
+// HMACKeyID sets the key id header identifying the key used to sign the request.
+func HMACKeyID(header, keyID string) HMACOptFn {
+	return func(o hmacOpt) hmacOpt {
+		o.keyIDHeader = header
+		o.keyID = keyID
+		return o.checkHeader("HMACKeyID", header)
+	}
+}
+
+// HMACTimestampHeader sets the header the signing timestamp of the
+// HMACTimestamp component is written to. Defaults to X-Timestamp.
+func HMACTimestampHeader(name string) HMACOptFn {
+	return func(o hmacOpt) hmacOpt {
+		o.timestampHeader = name
+		return o.checkHeader("HMACTimestampHeader", name)
+	}
+}
+
+// HMACNonceHeader sets the header the random nonce of the HMACNonce component is
+// written to. Defaults to X-Nonce.
+func HMACNonceHeader(name string) HMACOptFn {
+	return func(o hmacOpt) hmacOpt {
+		o.nonceHeader = name
+		return o.checkHeader("HMACNonceHeader", name)
+	}
+}
+
+// HMACDigestHeader sets the header the body digest is written to. The digest is
+// not sent when the header is not set.
+func HMACDigestHeader(name string) HMACOptFn {
+	return func(o hmacOpt) hmacOpt {
+		o.digestHeader = name
+		return o.checkHeader("HMACDigestHeader", name)
+	}
+}
+
+// HMACSignHeaders sets the headers included by the HMACHeaders component.
+func HMACSignHeaders(names ...string) HMACOptFn {
+	return func(o hmacOpt) hmacOpt {
+		o.signHeaders = names
+		for _, name := range names {
+			o = o.checkHeader("HMACSignHeaders", name)
+		}
+		return o
+	}
+}
+
+// checkHeader records an error for an empty header name, which fails every
+// request signed with the options.
+func (o hmacOpt) checkHeader(optName, name string) hmacOpt {
+	if o.err == nil && strings.TrimSpace(name) == "" {
+		o.err = fmt.Errorf("hmac: %s requires a non empty header name", optName)
+	}
+	return o
+}
+
+// HMACHexEncoding hex encodes the signature. Defaults to base64.
+func HMACHexEncoding() HMACOptFn {
+	return func(o hmacOpt) hmacOpt {
+		o.hexEncode = true
+		return o
+	}
+}
+
+// HMACNow sets the func used to obtain the signing time. Defaults to time.Now.
+func HMACNow(now func() time.Time) HMACOptFn {
+	return func(o hmacOpt) hmacOpt {
+		o.now = now
+		return o
+	}
+}
+
+// HMACAuth signs requests with an HMAC over the configured components of the
+// request. As an Authorizer, signing happens after the headers and query params
+// of the request are applied, and is repeated for every attempt. An option with
+// an empty header name fails every request with an error.
+func HMACAuth(key []byte, opts ...HMACOptFn) Authorizer {
+	opt := hmacOpt{
+		newHash:         sha256.New,
+		components:      []HMACComponent{HMACMethod, HMACPath, HMACQuery, HMACTimestamp, HMACBodyDigest},
+		signatureHeader: "X-Signature",
+		timestampHeader: "X-Timestamp",
+		nonceHeader:     "X-Nonce",
+		now:             time.Now,
+	}
+	for _, o := range opts {
+		opt = o(opt)
+	}
+
+	return AuthorizerFunc(func(ctx context.Context, r *http.Request) (*http.Request, error) {
+		if opt.err != nil {
+			return nil, opt.err
+		}
+		return signHMAC(key, opt, r)
+	})
+}
+
+func signHMAC(key []byte, opt hmacOpt, r *http.Request) (*http.Request, error) {
+	var timestamp string
+	if hasHMACComponent(opt.components, HMACTimestamp) {
+		timestamp = strconv.FormatInt(opt.now().Unix(), 10)
+		r.Header.Set(opt.timestampHeader, timestamp)
+	}
+
+	var nonce string
+	if hasHMACComponent(opt.components, HMACNonce) {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		nonce = hex.EncodeToString(b)
+		r.Header.Set(opt.nonceHeader, nonce)
+	}
+
+	if opt.keyIDHeader != "" {
+		r.Header.Set(opt.keyIDHeader, opt.keyID)
+	}
+
+	var digest string
+	if opt.digestHeader != "" || hasHMACComponent(opt.components, HMACBodyDigest) {
+		sum, err := bodyDigest(r, opt.newHash)
+		if err != nil {
+			return nil, err
+		}
+		digest = hex.EncodeToString(sum)
+		if opt.digestHeader != "" {
+			r.Header.Set(opt.digestHeader, digest)
+		}
+	}
+
+	lines := make([]string, 0, len(opt.components))
+	for _, c := range opt.components {
+		switch c {
+		case HMACMethod:
+			lines = append(lines, r.Method)
+		case HMACHost:
+			host := r.Host
+			if host == "" {
+				host = r.URL.Host
+			}
+			lines = append(lines, host)
+		case HMACPath:
+			lines = append(lines, r.URL.EscapedPath())
+		case HMACQuery:
+			lines = append(lines, sortedQuery(r.URL.Query()))
+		case HMACBodyDigest:
+			lines = append(lines, digest)
+		case HMACTimestamp:
+			lines = append(lines, timestamp)
+		case HMACNonce:
+			lines = append(lines, nonce)
+		case HMACHeaders:
+			for _, name := range opt.signHeaders {
+				lines = append(lines, strings.ToLower(name)+":"+strings.TrimSpace(r.Header.Get(name)))
+			}
+		}
+	}
+
+	mac := hmac.New(opt.newHash, key)
+	mac.Write([]byte(strings.Join(lines, "\n")))
+	sig := mac.Sum(nil)
+
+	if opt.hexEncode {
+		r.Header.Set(opt.signatureHeader, hex.EncodeToString(sig))
+	} else {
+		r.Header.Set(opt.signatureHeader, base64.StdEncoding.EncodeToString(sig))
+	}
+	return r, nil
+}
+
+func hasHMACComponent(components []HMACComponent, c HMACComponent) bool {
+	for _, comp := range components {
+		if comp == c {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedQuery encodes the query params sorted by key and then by value.
+func sortedQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
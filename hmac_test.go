@@ -0,0 +1,116 @@
+package httpc_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/httpc"
+)
+
+func TestHMACAuth(t *testing.T) {
+	signingTime := time.Unix(1700000000, 0)
+	key := []byte("secret")
+
+	t.Run("default components", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusOK)
+
+		auth := httpc.HMACAuth(key, httpc.HMACNow(func() time.Time { return signingTime }))
+		client := httpc.New(doer, httpc.WithAuth(auth))
+
+		err := client.
+			Post("/foo/bar").
+			QueryParams("b", "2", "a", "1").
+			Body(foo{Name: "name"}).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		mustEquals(t, 1, len(doer.args))
+		req := doer.args[0]
+
+		bodyDigest := sha256.Sum256([]byte(`{"Name":"name","S":"","Method":""}` + "\n"))
+		strToSign := "POST\n/foo/bar\na=1&b=2\n1700000000\n" + hex.EncodeToString(bodyDigest[:])
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(strToSign))
+
+		equals(t, "1700000000", req.Header.Get("X-Timestamp"))
+		equals(t, base64.StdEncoding.EncodeToString(mac.Sum(nil)), req.Header.Get("X-Signature"))
+	})
+
+	t.Run("configured canonicalization", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusOK)
+
+		auth := httpc.HMACAuth(key,
+			httpc.HMACNow(func() time.Time { return signingTime }),
+			httpc.HMACHash(sha1.New),
+			httpc.HMACHexEncoding(),
+			httpc.HMACComponents(httpc.HMACMethod, httpc.HMACHost, httpc.HMACNonce, httpc.HMACHeaders),
+			httpc.HMACSignHeaders("X-Account"),
+			httpc.HMACSignatureHeader("X-Sig"),
+			httpc.HMACKeyID("X-Key-Id", "key-1"),
+			httpc.HMACNonceHeader("X-Nonce"),
+		)
+		client := httpc.New(doer, httpc.WithAuth(auth))
+
+		err := client.
+			Get("https://api.example.com/foo").
+			Header("X-Account", " acct ").
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		mustEquals(t, 1, len(doer.args))
+		req := doer.args[0]
+
+		nonce := req.Header.Get("X-Nonce")
+		mustEquals(t, 32, len(nonce))
+
+		mac := hmac.New(sha1.New, key)
+		mac.Write([]byte("GET\napi.example.com\n" + nonce + "\nx-account:acct"))
+
+		equals(t, "key-1", req.Header.Get("X-Key-Id"))
+		equals(t, hex.EncodeToString(mac.Sum(nil)), req.Header.Get("X-Sig"))
+		equals(t, "", req.Header.Get("X-Timestamp"))
+	})
+
+	t.Run("headers of excluded components are not set", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusOK)
+
+		auth := httpc.HMACAuth(key,
+			httpc.HMACComponents(httpc.HMACMethod, httpc.HMACPath),
+			httpc.HMACNonceHeader("X-Request-Nonce"),
+		)
+		client := httpc.New(doer, httpc.WithAuth(auth))
+
+		err := client.Get("/foo").Success(httpc.StatusOK()).Do(context.TODO())
+		mustNoError(t, err)
+
+		mustEquals(t, 1, len(doer.args))
+		req := doer.args[0]
+
+		equals(t, "", req.Header.Get("X-Timestamp"))
+		equals(t, "", req.Header.Get("X-Request-Nonce"))
+		equals(t, true, req.Header.Get("X-Signature") != "")
+	})
+
+	t.Run("empty header name fails the request", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusOK)
+
+		auth := httpc.HMACAuth(key, httpc.HMACTimestampHeader(""))
+		client := httpc.New(doer, httpc.WithAuth(auth))
+
+		err := client.Get("/foo").Success(httpc.StatusOK()).Do(context.TODO())
+		mustError(t, err)
+
+		equals(t, true, strings.Contains(err.Error(), "HMACTimestampHeader"))
+		equals(t, 0, doer.doCallCount)
+	})
+}
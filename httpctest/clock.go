@@ -0,0 +1,120 @@
+// Package httpctest provides utilities for testing code that uses httpc.
+package httpctest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jsteenb2/httpc"
+)
+
+// FakeClock is a httpc.Clock whose time only moves when advanced, allowing the
+// waits of the retry loop to be asserted deterministically. A FakeClock is safe
+// for concurrent use.
+type FakeClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+	waits  []time.Duration
+}
+
+var _ httpc.Clock = (*FakeClock)(nil)
+
+// NewFakeClock creates a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the current time of the fake clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer creates a timer that fires once the clock is advanced by d.
+func (c *FakeClock) NewTimer(d time.Duration) httpc.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{
+		clock:    c,
+		c:        make(chan time.Time, 1),
+		deadline: c.now.Add(d),
+	}
+	c.waits = append(c.waits, d)
+	if d <= 0 {
+		t.c <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// Advance moves the clock forward by d, firing the timers that expire.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.deadline.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.c <- c.now
+	}
+	c.timers = pending
+	c.cond.Broadcast()
+}
+
+// BlockUntil blocks until n timers are waiting to fire.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// Waits returns the durations of all the timers created, in order.
+func (c *FakeClock) Waits() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	c        chan time.Time
+	deadline time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	for i, pending := range t.clock.timers {
+		if pending == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			t.clock.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package httpctest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/httpc/httpctest"
+)
+
+func TestFakeClock(t *testing.T) {
+	t.Run("advance fires expired timers", func(t *testing.T) {
+		start := time.Unix(100, 0)
+		clock := httpctest.NewFakeClock(start)
+
+		short := clock.NewTimer(time.Second)
+		long := clock.NewTimer(time.Minute)
+
+		clock.Advance(time.Second)
+		select {
+		case now := <-short.C():
+			equals(t, start.Add(time.Second), now)
+		default:
+			t.Fatal("expected short timer to fire")
+		}
+		select {
+		case <-long.C():
+			t.Fatal("unexpected long timer fired")
+		default:
+		}
+
+		equals(t, start.Add(time.Second), clock.Now())
+		equals(t, true, long.Stop())
+		equals(t, false, short.Stop())
+	})
+
+	t.Run("block until waits for timers", func(t *testing.T) {
+		clock := httpctest.NewFakeClock(time.Unix(0, 0))
+
+		fired := make(chan struct{})
+		go func() {
+			<-clock.NewTimer(time.Second).C()
+			close(fired)
+		}()
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+
+		select {
+		case <-fired:
+		case <-time.After(time.Second):
+			t.Fatal("expected timer to fire")
+		}
+
+		waits := clock.Waits()
+		equals(t, 1, len(waits))
+		equals(t, time.Second, waits[0])
+	})
+}
+
+func equals(t *testing.T, expected interface{}, actual interface{}) {
+	t.Helper()
+	if expected == actual {
+		return
+	}
+	t.Errorf("expected: %v\tgot: %v", expected, actual)
+}
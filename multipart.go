@@ -0,0 +1,351 @@
+package httpc
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type partOpt struct {
+	header textproto.MIMEHeader
+	size   int64
+}
+
+// PartOptFn is a optional parameter for a part of a Multipart body.
+type PartOptFn func(o partOpt) partOpt
+
+// PartContentType sets the Content-Type of the part. Defaults to the type of
+// the file's extension, or application/octet-stream when unknown.
+func PartContentType(cType string) PartOptFn {
+	return PartHeader("Content-Type", cType)
+}
+
+// PartHeader sets a header of the part.
+func PartHeader(key, value string) PartOptFn {
+	return func(o partOpt) partOpt {
+		o.header.Set(key, value)
+		return o
+	}
+}
+
+// PartSize sets the size of a part read from a reader, allowing the length of
+// the body to be sent as the Content-Length. Readers that provide a Len method,
+// i.e. a bytes.Reader, are sized without it.
+func PartSize(n int64) PartOptFn {
+	return func(o partOpt) partOpt {
+		o.size = n
+		return o
+	}
+}
+
+// Multipart is a multipart/form-data body built up from fields and files. When
+// set as the body of a Request it bypasses the encoder, the parts are streamed
+// rather than buffered and the Content-Type, with the boundary, is set
+// automatically. When the size of every part is known, the length of the body
+// is sent as the Content-Length, otherwise the body is sent chunked.
+//
+// A request with a Multipart body is retried only if every part can be read
+// again: fields, files from a path and readers that are an io.Seeker. It is
+// hedged only if every part is a field or a file from a path, as the attempts
+// would read a reader at the same time.
+type Multipart struct {
+	boundary string
+	parts    []multipartPart
+}
+
+type multipartPart struct {
+	header     textproto.MIMEHeader
+	size       func() int64
+	open       func() (io.Reader, func() error, error)
+	replayable bool
+	// concurrent is set when open returns a reader of its own, so that the part
+	// can be read by more than one attempt at the same time.
+	concurrent bool
+}
+
+// NewMultipart creates a Multipart body with a random boundary.
+func NewMultipart() *Multipart {
+	return &Multipart{
+		boundary: multipart.NewWriter(ioutil.Discard).Boundary(),
+	}
+}
+
+// ContentType returns the Content-Type of the body, including the boundary.
+func (m *Multipart) ContentType() string {
+	return m.writer(ioutil.Discard).FormDataContentType()
+}
+
+// Field adds a form field.
+func (m *Multipart) Field(name, value string) *Multipart {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(name)))
+	return m.add(multipartPart{
+		header: h,
+		size:   func() int64 { return int64(len(value)) },
+		open: func() (io.Reader, func() error, error) {
+			return strings.NewReader(value), noopClose, nil
+		},
+		replayable: true,
+		concurrent: true,
+	})
+}
+
+// File adds a file field with the contents of the file at path. The file is
+// opened when the body is sent.
+func (m *Multipart) File(name, path string, opts ...PartOptFn) *Multipart {
+	opt := newPartOpt(name, filepath.Base(path), opts)
+	return m.add(multipartPart{
+		header: opt.header,
+		size: func() int64 {
+			fi, err := os.Stat(path)
+			if err != nil {
+				return -1
+			}
+			return fi.Size()
+		},
+		open: func() (io.Reader, func() error, error) {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, nil, err
+			}
+			return f, f.Close, nil
+		},
+		replayable: true,
+		concurrent: true,
+	})
+}
+
+// FileReader adds a file field with the contents read from r.
+func (m *Multipart) FileReader(name, filename string, r io.Reader, opts ...PartOptFn) *Multipart {
+	opt := newPartOpt(name, filename, opts)
+	return m.add(readerPart(opt, r))
+}
+
+// Part adds a part with the provided headers and the contents read from r.
+func (m *Multipart) Part(header textproto.MIMEHeader, r io.Reader, opts ...PartOptFn) *Multipart {
+	opt := partOpt{header: make(textproto.MIMEHeader), size: -1}
+	for k, v := range header {
+		opt.header[k] = append([]string(nil), v...)
+	}
+	for _, o := range opts {
+		opt = o(opt)
+	}
+	return m.add(readerPart(opt, r))
+}
+
+func (m *Multipart) add(p multipartPart) *Multipart {
+	m.parts = append(m.parts, p)
+	return m
+}
+
+func (m *Multipart) writer(w io.Writer) *multipart.Writer {
+	mw := multipart.NewWriter(w)
+	mw.SetBoundary(m.boundary)
+	return mw
+}
+
+// size returns the length of the encoded body, or -1 when the size of a part is
+// unknown.
+func (m *Multipart) size() int64 {
+	var cw countWriter
+	mw := m.writer(&cw)
+
+	var total int64
+	for _, p := range m.parts {
+		n := p.size()
+		if n < 0 {
+			return -1
+		}
+		if _, err := mw.CreatePart(p.header); err != nil {
+			return -1
+		}
+		total += n
+	}
+	if err := mw.Close(); err != nil {
+		return -1
+	}
+	return total + cw.n
+}
+
+func (m *Multipart) replayable() bool {
+	for _, p := range m.parts {
+		if !p.replayable {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Multipart) concurrentReplayable() bool {
+	for _, p := range m.parts {
+		if !p.concurrent {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Multipart) writeTo(w io.Writer) error {
+	mw := m.writer(w)
+	for _, p := range m.parts {
+		pw, err := mw.CreatePart(p.header)
+		if err != nil {
+			return err
+		}
+
+		r, closeFn, err := p.open()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(pw, r)
+		closeFn()
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// reader returns the body, which is written on the first read.
+func (m *Multipart) reader() io.ReadCloser {
+	pr, pw := io.Pipe()
+	return &pipeBody{
+		pr: pr,
+		start: func() {
+			go func() {
+				pw.CloseWithError(m.writeTo(pw))
+			}()
+		},
+	}
+}
+
+func newPartOpt(name, filename string, opts []PartOptFn) partOpt {
+	opt := partOpt{header: make(textproto.MIMEHeader), size: -1}
+	opt.header.Set("Content-Disposition",
+		fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(name), escapeQuotes(filename)))
+
+	cType := mime.TypeByExtension(filepath.Ext(filename))
+	if cType == "" {
+		cType = "application/octet-stream"
+	}
+	opt.header.Set("Content-Type", cType)
+
+	for _, o := range opts {
+		opt = o(opt)
+	}
+	return opt
+}
+
+func readerPart(opt partOpt, r io.Reader) multipartPart {
+	size := opt.size
+	if l, ok := r.(interface{ Len() int }); ok && size < 0 {
+		size = int64(l.Len())
+	}
+
+	part := multipartPart{
+		header: opt.header,
+		size:   func() int64 { return size },
+		open: func() (io.Reader, func() error, error) {
+			return r, noopClose, nil
+		},
+	}
+
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		return part
+	}
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return part
+	}
+	part.open = func() (io.Reader, func() error, error) {
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return nil, nil, err
+		}
+		return r, noopClose, nil
+	}
+	part.replayable = true
+	return part
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+func noopClose() error {
+	return nil
+}
+
+type countWriter struct {
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// pipeBody is the read side of a pipe whose writer is started on the first
+// read, so that a body that is never sent holds no resources.
+type pipeBody struct {
+	pr    *io.PipeReader
+	once  sync.Once
+	start func()
+}
+
+func (p *pipeBody) Read(b []byte) (int, error) {
+	p.once.Do(p.start)
+	return p.pr.Read(b)
+}
+
+func (p *pipeBody) Close() error {
+	return p.pr.Close()
+}
+
+// multipartBody is the request body of a Multipart.
+type multipartBody struct {
+	m    *Multipart
+	sent bool
+}
+
+func (b *multipartBody) setOn(req *http.Request) error {
+	if b.sent && !b.m.replayable() {
+		return ErrBodyNotReplayable
+	}
+	b.sent = true
+
+	req.Body = b.m.reader()
+	req.ContentLength = b.m.size()
+	if b.m.replayable() {
+		req.GetBody = func() (io.ReadCloser, error) {
+			return b.m.reader(), nil
+		}
+	}
+	return nil
+}
+
+func (b *multipartBody) contentType() string {
+	return b.m.ContentType()
+}
+
+func (b *multipartBody) replayable() bool {
+	return b.m.replayable()
+}
+
+func (b *multipartBody) concurrentReplayable() bool {
+	return b.m.concurrentReplayable()
+}
+
+func (b *multipartBody) close() error {
+	return nil
+}
@@ -0,0 +1,267 @@
+package httpc_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/httpc"
+)
+
+func TestMultipart(t *testing.T) {
+	t.Run("streams fields and files", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "report.txt")
+		mustNoError(t, os.WriteFile(path, []byte("file contents"), 0600))
+
+		type received struct {
+			contentLength int64
+			fields        map[string]string
+			files         map[string]string
+			headers       map[string]textproto.MIMEHeader
+		}
+		var got received
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = received{
+				contentLength: r.ContentLength,
+				fields:        make(map[string]string),
+				files:         make(map[string]string),
+				headers:       make(map[string]textproto.MIMEHeader),
+			}
+			mr, err := r.MultipartReader()
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			for {
+				p, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				b, _ := ioutil.ReadAll(p)
+				got.headers[p.FormName()] = p.Header
+				if p.FileName() != "" {
+					got.files[p.FileName()] = string(b)
+					continue
+				}
+				got.fields[p.FormName()] = string(b)
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer svr.Close()
+
+		client := httpc.New(svr.Client(), httpc.WithBaseURL(svr.URL), httpc.WithContentType("application/json"))
+
+		body := httpc.NewMultipart().
+			Field("name", "value").
+			File("report", path).
+			FileReader("data", "data.bin", strings.NewReader("reader contents"),
+				httpc.PartHeader("X-Part", "custom"),
+			)
+
+		err := client.
+			Post("/upload").
+			Body(body).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, true, got.contentLength > 0)
+		equals(t, "value", got.fields["name"])
+		equals(t, "file contents", got.files["report.txt"])
+		equals(t, "reader contents", got.files["data.bin"])
+		equals(t, "text/plain; charset=utf-8", got.headers["report"].Get("Content-Type"))
+		equals(t, "application/octet-stream", got.headers["data"].Get("Content-Type"))
+		equals(t, "custom", got.headers["data"].Get("X-Part"))
+	})
+
+	t.Run("sets content type and length", func(t *testing.T) {
+		body := httpc.NewMultipart().
+			Field("a", "1").
+			Part(textproto.MIMEHeader{"Content-Type": {"application/json"}}, bytes.NewReader([]byte(`{}`)))
+
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			mustNoError(t, err)
+			equals(t, "multipart/form-data", mediaType)
+			equals(t, body.ContentType(), r.Header.Get("Content-Type"))
+
+			b, err := ioutil.ReadAll(r.Body)
+			mustNoError(t, err)
+			equals(t, int64(len(b)), r.ContentLength)
+
+			mr := multipart.NewReader(bytes.NewReader(b), params["boundary"])
+			form, err := mr.ReadForm(1 << 20)
+			mustNoError(t, err)
+			equals(t, "1", form.Value["a"][0])
+			return stubResp(http.StatusOK), nil
+		}
+
+		client := httpc.New(doer)
+
+		err := client.
+			Post("/upload").
+			Body(body).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+	})
+
+	t.Run("unknown size is sent chunked", func(t *testing.T) {
+		body := httpc.NewMultipart().
+			FileReader("data", "data.bin", ioutil.NopCloser(strings.NewReader("contents")))
+
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			equals(t, int64(-1), r.ContentLength)
+			return stubResp(http.StatusOK), nil
+		}
+
+		client := httpc.New(doer)
+
+		err := client.Post("/upload").Body(body).Success(httpc.StatusOK()).Do(context.TODO())
+		mustNoError(t, err)
+	})
+
+	t.Run("replayable parts are retried", func(t *testing.T) {
+		var bodies []string
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				return nil, err
+			}
+			bodies = append(bodies, string(b))
+			return stubResp(http.StatusServiceUnavailable), nil
+		}
+
+		client := httpc.New(doer, httpc.WithBackoff(httpc.NewConstantBackoff(time.Nanosecond, 3)))
+
+		err := client.
+			Post("/upload").
+			Body(httpc.NewMultipart().FileReader("data", "data.bin", strings.NewReader("contents"))).
+			Success(httpc.StatusOK()).
+			Retry(httpc.RetryStatus(httpc.StatusServiceUnavailable())).
+			Do(context.TODO())
+		mustError(t, err)
+
+		mustEquals(t, 3, len(bodies))
+		equals(t, bodies[0], bodies[2])
+		equals(t, true, strings.Contains(bodies[2], "contents"))
+	})
+
+	t.Run("one shot readers are not retried", func(t *testing.T) {
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			ioutil.ReadAll(r.Body)
+			return stubResp(http.StatusServiceUnavailable), nil
+		}
+
+		client := httpc.New(doer, httpc.WithBackoff(httpc.NewConstantBackoff(time.Nanosecond, 3)))
+
+		body := httpc.NewMultipart().
+			FileReader("data", "data.bin", ioutil.NopCloser(strings.NewReader("contents")))
+		err := client.
+			Post("/upload").
+			Body(body).
+			Success(httpc.StatusOK()).
+			Retry(httpc.RetryStatus(httpc.StatusServiceUnavailable())).
+			Do(context.TODO())
+		mustError(t, err)
+
+		equals(t, false, retryErr(err))
+		equals(t, 1, doer.doCallCount)
+	})
+
+	t.Run("reader parts are not hedged", func(t *testing.T) {
+		var (
+			mu     sync.Mutex
+			bodies []string
+		)
+		doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+			time.Sleep(20 * time.Millisecond)
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			bodies = append(bodies, string(b))
+			mu.Unlock()
+			return stubResp(http.StatusOK), nil
+		})
+
+		hedger := httpc.NewHedger(httpc.HedgeDelay(time.Millisecond), httpc.HedgeMethods(http.MethodPut))
+		client := httpc.New(doer, httpc.WithHedger(hedger))
+
+		data := bytes.Repeat([]byte("x"), 512<<10)
+		err := client.
+			Put("/upload").
+			Body(httpc.NewMultipart().FileReader("data", "data.bin", bytes.NewReader(data))).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		mu.Lock()
+		defer mu.Unlock()
+		mustEquals(t, 1, len(bodies))
+		equals(t, true, strings.Contains(bodies[0], string(data)))
+		equals(t, int64(0), hedger.Stats().Hedged)
+	})
+
+	t.Run("fields and files are hedged", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "report.txt")
+		mustNoError(t, os.WriteFile(path, []byte("file contents"), 0600))
+
+		var (
+			mu     sync.Mutex
+			bodies []string
+		)
+		doer := httpc.DoerFunc(func(r *http.Request) (*http.Response, error) {
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			bodies = append(bodies, string(b))
+			first := len(bodies) == 1
+			mu.Unlock()
+			if first {
+				<-r.Context().Done()
+				return nil, r.Context().Err()
+			}
+			return stubResp(http.StatusOK), nil
+		})
+
+		hedger := httpc.NewHedger(httpc.HedgeDelay(time.Millisecond), httpc.HedgeMethods(http.MethodPut))
+		client := httpc.New(doer, httpc.WithHedger(hedger))
+
+		err := client.
+			Put("/upload").
+			Body(httpc.NewMultipart().Field("name", "report").File("report", path)).
+			Success(httpc.StatusOK()).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		mu.Lock()
+		defer mu.Unlock()
+		mustEquals(t, 2, len(bodies))
+		equals(t, bodies[0], bodies[1])
+		equals(t, int64(1), hedger.Stats().Hedged)
+	})
+}
@@ -0,0 +1,273 @@
+package httpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token is an OAuth2 token returned from a token endpoint.
+type Token struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+
+	// Expiry is the time the access token expires. A zero Expiry means the
+	// token does not expire.
+	Expiry time.Time
+}
+
+func (t *Token) expired(delta time.Duration) bool {
+	if t.Expiry.IsZero() {
+		return false
+	}
+	return !time.Now().Add(delta).Before(t.Expiry)
+}
+
+// OAuth2Error is the error response of a token endpoint, as defined by RFC 6749
+// section 5.2. It is wrapped by the error returned when a token can not be
+// obtained, and can be retrieved with errors.As.
+type OAuth2Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	URI         string `json:"error_uri"`
+}
+
+// Error returns the error code and description.
+func (e *OAuth2Error) Error() string {
+	msg := "oauth2: " + e.Code
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	return msg
+}
+
+// TokenSource provides OAuth2 tokens.
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// CachedTokenSource caches the token from the underlying TokenSource until it
+// is about to expire, and is safe for concurrent use. Concurrent callers that
+// require a new token wait on a single fetch.
+type CachedTokenSource struct {
+	src         TokenSource
+	expiryDelta time.Duration
+
+	mu  sync.Mutex
+	tok *Token
+}
+
+// NewCachedTokenSource creates a CachedTokenSource. Tokens are refreshed when
+// they are within expiryDelta of their expiry.
+func NewCachedTokenSource(src TokenSource, expiryDelta time.Duration) *CachedTokenSource {
+	return &CachedTokenSource{
+		src:         src,
+		expiryDelta: expiryDelta,
+	}
+}
+
+// Token returns the cached token, fetching a new one when no valid token is cached.
+func (s *CachedTokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tok != nil && !s.tok.expired(s.expiryDelta) {
+		return s.tok, nil
+	}
+
+	tok, err := s.src.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.tok = tok
+	return tok, nil
+}
+
+// Invalidate drops the cached token when it matches the access token provided,
+// forcing the next call to Token to fetch a new token. A token that has already
+// been replaced is left untouched, so concurrent callers holding the same
+// rejected token trigger a single refresh.
+func (s *CachedTokenSource) Invalidate(accessToken string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tok != nil && s.tok.AccessToken == accessToken {
+		s.tok = nil
+	}
+}
+
+type oauth2Opt struct {
+	expiryDelta time.Duration
+	params      url.Values
+}
+
+// OAuth2OptFn is a optional parameter for the OAuth2 token sources.
+type OAuth2OptFn func(o oauth2Opt) oauth2Opt
+
+// OAuth2ExpiryDelta sets how long before the token's expiry a new token is
+// fetched. Defaults to 10 seconds.
+func OAuth2ExpiryDelta(d time.Duration) OAuth2OptFn {
+	return func(o oauth2Opt) oauth2Opt {
+		o.expiryDelta = d
+		return o
+	}
+}
+
+// OAuth2Param adds a param to the token request, i.e. audience or resource.
+func OAuth2Param(key, value string) OAuth2OptFn {
+	return func(o oauth2Opt) oauth2Opt {
+		o.params.Add(key, value)
+		return o
+	}
+}
+
+// NewClientCredentialsSource creates a cached token source that fetches tokens
+// using the OAuth2 client credentials grant. The client id and secret are sent
+// to the token endpoint with basic auth.
+func NewClientCredentialsSource(doer Doer, tokenURL, clientID, clientSecret string, scopes []string, opts ...OAuth2OptFn) *CachedTokenSource {
+	params := url.Values{"grant_type": {"client_credentials"}}
+	if len(scopes) > 0 {
+		params.Set("scope", strings.Join(scopes, " "))
+	}
+	return newOAuth2Source(doer, tokenURL, clientID, clientSecret, params, opts)
+}
+
+// NewRefreshTokenSource creates a cached token source that fetches tokens using
+// the OAuth2 refresh token grant. When the token endpoint rotates the refresh
+// token, the new refresh token is used for subsequent refreshes.
+func NewRefreshTokenSource(doer Doer, tokenURL, clientID, clientSecret, refreshToken string, opts ...OAuth2OptFn) *CachedTokenSource {
+	params := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}}
+	return newOAuth2Source(doer, tokenURL, clientID, clientSecret, params, opts)
+}
+
+func newOAuth2Source(doer Doer, tokenURL, clientID, clientSecret string, params url.Values, opts []OAuth2OptFn) *CachedTokenSource {
+	opt := oauth2Opt{
+		expiryDelta: 10 * time.Second,
+		params:      params,
+	}
+	for _, o := range opts {
+		opt = o(opt)
+	}
+
+	src := &tokenEndpoint{
+		client: New(doer,
+			WithAuth(BasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))),
+			WithEncoder(FormEncoder()),
+		),
+		tokenURL: tokenURL,
+		params:   opt.params,
+	}
+	return NewCachedTokenSource(src, opt.expiryDelta)
+}
+
+type tokenEndpoint struct {
+	client   *Client
+	tokenURL string
+	params   url.Values
+}
+
+func (e *tokenEndpoint) Token(ctx context.Context) (*Token, error) {
+	var resp struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	err := e.client.
+		Post(e.tokenURL).
+		Body(e.params).
+		Success(StatusOK()).
+		Retry(RetryStatus(StatusInRange(http.StatusInternalServerError, 599))).
+		Decode(JSONDecode(&resp)).
+		OnError(decodeOAuth2Error).
+		Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.AccessToken == "" {
+		return nil, errors.New("oauth2: server response missing access_token")
+	}
+
+	tok := Token{
+		AccessToken:  resp.AccessToken,
+		TokenType:    resp.TokenType,
+		RefreshToken: resp.RefreshToken,
+	}
+	if resp.ExpiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	if tok.RefreshToken != "" && e.params.Get("grant_type") == "refresh_token" {
+		e.params.Set("refresh_token", tok.RefreshToken)
+	}
+	return &tok, nil
+}
+
+// decodeOAuth2Error decodes the error response of a token endpoint into an
+// OAuth2Error, which is set as the error of the failed request.
+func decodeOAuth2Error(r io.Reader) error {
+	var errResp OAuth2Error
+	if err := json.NewDecoder(r).Decode(&errResp); err != nil || errResp.Code == "" {
+		return nil
+	}
+	return &errResp
+}
+
+// OAuth2Auth sets the bearer token provided by the token source on the request.
+// If a token can not be obtained the request is not sent. Failures from the
+// token endpoint with a 5xx status code are retryable.
+func OAuth2Auth(src TokenSource) Authorizer {
+	return AuthorizerFunc(func(ctx context.Context, r *http.Request) (*http.Request, error) {
+		tok, err := src.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+		return r, nil
+	})
+}
+
+// RetryUnauthorized forces one refresh-and-retry when a response is a 401
+// Unauthorized. The rejected token is invalidated, a new token is fetched and
+// the request is sent again, independent of the request's backoff policy. The
+// retry is skipped when the request's body can not be replayed.
+func RetryUnauthorized(src *CachedTokenSource) RetryFn {
+	return func(r *Request) *Request {
+		return r.Use(func(next Doer) Doer {
+			return DoerFunc(func(req *http.Request) (*http.Response, error) {
+				resp, err := next.Do(req)
+				if err != nil || resp.StatusCode != http.StatusUnauthorized {
+					return resp, err
+				}
+				if req.Body != nil && req.GetBody == nil {
+					return resp, nil
+				}
+
+				src.Invalidate(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "))
+				tok, err := src.Token(req.Context())
+				if err != nil {
+					return resp, nil
+				}
+
+				retryReq := req.Clone(req.Context())
+				if req.GetBody != nil {
+					body, err := req.GetBody()
+					if err != nil {
+						return resp, nil
+					}
+					retryReq.Body = body
+				}
+				retryReq.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+
+				drain(resp.Body)
+				return next.Do(retryReq)
+			})
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package httpc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jsteenb2/httpc"
+)
+
+func TestOAuth2(t *testing.T) {
+	t.Run("client credentials", func(t *testing.T) {
+		t.Run("fetches and caches token", func(t *testing.T) {
+			tokenSvr := newTokenServer(t, 3600)
+
+			src := httpc.NewClientCredentialsSource(http.DefaultClient, tokenSvr.URL+"/token", "id", "secret", []string{"read", "write"})
+
+			doer := newAuthEchoDoer(t)
+			client := httpc.New(doer, httpc.WithAuth(httpc.OAuth2Auth(src)))
+
+			for i := 0; i < 3; i++ {
+				var actual foo
+				err := client.
+					Get("/foo").
+					Success(httpc.StatusOK()).
+					Decode(httpc.JSONDecode(&actual)).
+					Do(context.TODO())
+				mustNoError(t, err)
+
+				equals(t, "Bearer token-1", actual.Name)
+			}
+
+			equals(t, int64(1), tokenSvr.calls())
+			equals(t, "client_credentials", tokenSvr.lastForm().Get("grant_type"))
+			equals(t, "read write", tokenSvr.lastForm().Get("scope"))
+		})
+
+		t.Run("refreshes ahead of expiry", func(t *testing.T) {
+			tokenSvr := newTokenServer(t, 5)
+
+			src := httpc.NewClientCredentialsSource(http.DefaultClient, tokenSvr.URL+"/token", "id", "secret", nil)
+
+			for i := 1; i <= 2; i++ {
+				tok, err := src.Token(context.TODO())
+				mustNoError(t, err)
+
+				equals(t, "token-"+strconv.Itoa(i), tok.AccessToken)
+			}
+			equals(t, int64(2), tokenSvr.calls())
+		})
+
+		t.Run("concurrent requests share a single fetch", func(t *testing.T) {
+			tokenSvr := newTokenServer(t, 3600)
+
+			src := httpc.NewClientCredentialsSource(http.DefaultClient, tokenSvr.URL+"/token", "id", "secret", nil)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if _, err := src.Token(context.TODO()); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			equals(t, int64(1), tokenSvr.calls())
+		})
+
+		t.Run("token endpoint error", func(t *testing.T) {
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"invalid_client","error_description":"unknown client id"}`))
+			}))
+			defer svr.Close()
+
+			src := httpc.NewClientCredentialsSource(http.DefaultClient, svr.URL, "id", "bad", nil)
+
+			_, err := src.Token(context.TODO())
+			mustError(t, err)
+
+			var oauthErr *httpc.OAuth2Error
+			mustEquals(t, true, errors.As(err, &oauthErr))
+			equals(t, "invalid_client", oauthErr.Code)
+			equals(t, "unknown client id", oauthErr.Description)
+			equals(t, true, strings.Contains(err.Error(), "oauth2: invalid_client: unknown client id"))
+		})
+
+		t.Run("token failure fails the request", func(t *testing.T) {
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer svr.Close()
+
+			src := httpc.NewClientCredentialsSource(http.DefaultClient, svr.URL, "id", "secret", nil)
+
+			doer := newAuthEchoDoer(t)
+			client := httpc.New(doer, httpc.WithAuth(httpc.OAuth2Auth(src)))
+
+			err := client.
+				Get("/foo").
+				Success(httpc.StatusOK()).
+				Do(context.TODO())
+			mustError(t, err)
+
+			isRetryErr(t, err)
+			equals(t, 0, doer.doCallCount)
+		})
+	})
+
+	t.Run("refresh token rotates", func(t *testing.T) {
+		tokenSvr := newTokenServer(t, 0)
+
+		src := httpc.NewRefreshTokenSource(http.DefaultClient, tokenSvr.URL+"/token", "id", "secret", "refresh-0")
+
+		for i := 1; i <= 2; i++ {
+			src.Invalidate("token-" + strconv.Itoa(i-1))
+			tok, err := src.Token(context.TODO())
+			mustNoError(t, err)
+
+			equals(t, "token-"+strconv.Itoa(i), tok.AccessToken)
+			equals(t, "refresh-"+strconv.Itoa(i-1), tokenSvr.lastForm().Get("refresh_token"))
+			equals(t, "refresh_token", tokenSvr.lastForm().Get("grant_type"))
+		}
+	})
+
+	t.Run("retry unauthorized refreshes once", func(t *testing.T) {
+		tokenSvr := newTokenServer(t, 3600)
+
+		src := httpc.NewClientCredentialsSource(http.DefaultClient, tokenSvr.URL+"/token", "id", "secret", nil)
+
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			if r.Header.Get("Authorization") != "Bearer token-2" {
+				return stubResp(http.StatusUnauthorized), nil
+			}
+			var f foo
+			if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+				t.Fatal(err)
+			}
+			return stubRespNBody(t, http.StatusOK, f), nil
+		}
+
+		client := httpc.New(doer, httpc.WithAuth(httpc.OAuth2Auth(src)))
+
+		var actual foo
+		err := client.
+			Post("/foo").
+			Body(foo{Name: "name"}).
+			Success(httpc.StatusOK()).
+			Retry(httpc.RetryUnauthorized(src)).
+			Decode(httpc.JSONDecode(&actual)).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, "name", actual.Name)
+		equals(t, 2, doer.doCallCount)
+		equals(t, int64(2), tokenSvr.calls())
+	})
+}
+
+type tokenServer struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	count int64
+	form  url.Values
+}
+
+func newTokenServer(t *testing.T, expiresIn int) *tokenServer {
+	t.Helper()
+
+	ts := new(tokenServer)
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "id" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		ts.mu.Lock()
+		ts.form = r.PostForm
+		ts.mu.Unlock()
+		n := atomic.AddInt64(&ts.count, 1)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "token-" + strconv.FormatInt(n, 10),
+			"token_type":    "bearer",
+			"refresh_token": "refresh-" + strconv.FormatInt(n, 10),
+			"expires_in":    expiresIn,
+		})
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func (ts *tokenServer) calls() int64 {
+	return atomic.LoadInt64(&ts.count)
+}
+
+func (ts *tokenServer) lastForm() url.Values {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.form
+}
+
+func newAuthEchoDoer(t *testing.T) *fakeDoer {
+	doer := new(fakeDoer)
+	doer.doFn = func(r *http.Request) (*http.Response, error) {
+		return stubRespNBody(t, http.StatusOK, foo{Name: r.Header.Get("Authorization"), Method: r.Method}), nil
+	}
+	return doer
+}
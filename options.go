@@ -1,14 +1,25 @@
 package httpc
 
+import "time"
+
 // ClientOptFn sets keys on a client type.
 type ClientOptFn func(Client) Client
 
-// WithAuth sets the authorization func on the client type,
-// and will be used as the default authFn for all requests
+// WithAttemptTimeout sets the timeout of each attempt of a request from the
+// client. An attempt that times out is retried as directed by the backoff.
+func WithAttemptTimeout(d time.Duration) ClientOptFn {
+	return func(c Client) Client {
+		c.attemptTimeout = d
+		return c
+	}
+}
+
+// WithAuth sets the Authorizer on the client type, i.e. an AuthFn,
+// and will be used as the default Authorizer for all requests
 // from this client unless overwritten atn the request lvl.
-func WithAuth(authFn AuthFn) ClientOptFn {
+func WithAuth(auth Authorizer) ClientOptFn {
 	return func(c Client) Client {
-		c.authFn = authFn
+		c.auth = auth
 		return c
 	}
 }
@@ -30,6 +41,43 @@ func WithBaseURL(baseURL string) ClientOptFn {
 	}
 }
 
+// WithBodySpillThreshold sets the size in bytes past which an encoded request
+// body is buffered in a temp file rather than in memory, for replaying the body
+// on retries. Defaults to 32MiB.
+func WithBodySpillThreshold(n int64) ClientOptFn {
+	return func(c Client) Client {
+		c.spillThreshold = n
+		return c
+	}
+}
+
+// WithCircuitBreaker sets the circuit breaker shared by all requests from the
+// client.
+func WithCircuitBreaker(cb *CircuitBreaker) ClientOptFn {
+	return func(c Client) Client {
+		c.breaker = cb
+		return c
+	}
+}
+
+// WithClock sets the clock the retry loop waits on between attempts. Defaults
+// to the time package.
+func WithClock(clock Clock) ClientOptFn {
+	return func(c Client) Client {
+		c.clock = clock
+		return c
+	}
+}
+
+// WithCodecs sets the codecs the response body is decoded with, by its
+// Content-Type, when a request uses DecodeContent. Defaults to DefaultCodecs.
+func WithCodecs(codecs Codecs) ClientOptFn {
+	return func(c Client) Client {
+		c.codecs = codecs
+		return c
+	}
+}
+
 // WithContentType sets content type that will be applied to all requests.
 func WithContentType(cType string) ClientOptFn {
 	return func(c Client) Client {
@@ -38,10 +86,12 @@ func WithContentType(cType string) ClientOptFn {
 	}
 }
 
-// WithEncoder sets the encode func for the client.
-func WithEncoder(fn EncodeFn) ClientOptFn {
+// WithEncoder sets the encoder for the client. The Content-Type of requests with
+// a body is set to the media type of the encoder, unless set by the caller, an
+// EncodeFn leaves it to be set by the caller.
+func WithEncoder(enc Encoder) ClientOptFn {
 	return func(c Client) Client {
-		c.encodeFn = fn
+		c.encoder = enc
 		return c
 	}
 }
@@ -53,3 +103,77 @@ func WithHeader(key, value string) ClientOptFn {
 		return c
 	}
 }
+
+// WithHedger sets the hedger applied to requests from the client with an
+// idempotent method.
+func WithHedger(h *Hedger) ClientOptFn {
+	return func(c Client) Client {
+		c.hedger = h
+		return c
+	}
+}
+
+// WithMaxRetryWait caps the wait between attempts for all requests, including
+// server directed delays from a Retry-After header. A zero value leaves the wait
+// uncapped.
+func WithMaxRetryWait(d time.Duration) ClientOptFn {
+	return func(c Client) Client {
+		c.maxRetryWait = d
+		return c
+	}
+}
+
+// WithMiddleware appends middleware that will be applied to all requests. The
+// first middleware provided is the outermost, and client middleware always wraps
+// middleware added to a request via Use.
+func WithMiddleware(mw ...Middleware) ClientOptFn {
+	return func(c Client) Client {
+		c.middleware = append(append([]Middleware(nil), c.middleware...), mw...)
+		return c
+	}
+}
+
+// WithOnGiveUp appends a hook that is called when a retriable error of a request
+// from the client is no longer retried.
+func WithOnGiveUp(fn GiveUpFn) ClientOptFn {
+	return func(c Client) Client {
+		c.onGiveUp = append(append([]GiveUpFn(nil), c.onGiveUp...), fn)
+		return c
+	}
+}
+
+// WithOnRetry appends a hook that is called before each retry of a request from
+// the client.
+func WithOnRetry(fn RetryNotifyFn) ClientOptFn {
+	return func(c Client) Client {
+		c.onRetry = append(append([]RetryNotifyFn(nil), c.onRetry...), fn)
+		return c
+	}
+}
+
+// WithRateLimiter sets the rate limiter shared by all requests from the client.
+func WithRateLimiter(l *RateLimiter) ClientOptFn {
+	return func(c Client) Client {
+		c.limiter = l
+		return c
+	}
+}
+
+// WithRetryBudget sets the retry budget shared by all requests from the client.
+// The budget is consulted before every retry, and a retry that exceeds the
+// budget fails the request with a RetryBudgetErr.
+func WithRetryBudget(b *RetryBudget) ClientOptFn {
+	return func(c Client) Client {
+		c.budget = b
+		return c
+	}
+}
+
+// WithTimeout sets the deadline of a request from the client, including all
+// attempts and the waits between them.
+func WithTimeout(d time.Duration) ClientOptFn {
+	return func(c Client) Client {
+		c.timeout = d
+		return c
+	}
+}
@@ -0,0 +1,355 @@
+package httpc
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type rateLimitOpt struct {
+	global rateLimit
+	host   rateLimit
+	route  rateLimit
+	keyFn  func(*http.Request) string
+
+	decrease, increase float64
+	onWait             func(key string, wait time.Duration)
+	maxBuckets         int
+}
+
+type rateLimit struct {
+	rate  float64
+	burst int
+}
+
+// RateLimitOptFn is a optional parameter for a RateLimiter.
+type RateLimitOptFn func(o rateLimitOpt) rateLimitOpt
+
+// RateLimitGlobal limits all requests to rate requests per second, allowing
+// bursts of up to burst requests.
+func RateLimitGlobal(rate float64, burst int) RateLimitOptFn {
+	return func(o rateLimitOpt) rateLimitOpt {
+		o.global = rateLimit{rate: rate, burst: burst}
+		return o
+	}
+}
+
+// RateLimitPerHost limits the requests to each host to rate requests per second,
+// allowing bursts of up to burst requests.
+func RateLimitPerHost(rate float64, burst int) RateLimitOptFn {
+	return func(o rateLimitOpt) rateLimitOpt {
+		o.host = rateLimit{rate: rate, burst: burst}
+		return o
+	}
+}
+
+// RateLimitPerRoute limits the requests to each route, as keyed by the keyFn, to
+// rate requests per second, allowing bursts of up to burst requests.
+func RateLimitPerRoute(keyFn func(*http.Request) string, rate float64, burst int) RateLimitOptFn {
+	return func(o rateLimitOpt) rateLimitOpt {
+		o.keyFn = keyFn
+		o.route = rateLimit{rate: rate, burst: burst}
+		return o
+	}
+}
+
+// RateLimitAdaptive lowers the rate of the limits a request is subject to when a
+// 429 Too Many Requests response is received, multiplying the rate by decrease,
+// down to a floor of 1% of the configured rate. Each successful response raises
+// the rate by increase times the configured rate, up to the configured rate.
+func RateLimitAdaptive(decrease, increase float64) RateLimitOptFn {
+	return func(o rateLimitOpt) rateLimitOpt {
+		o.decrease = decrease
+		o.increase = increase
+		return o
+	}
+}
+
+// RateLimitMaxBuckets caps the number of per host and per route limits held by
+// the limiter, defaulting to 1024. Once the cap is reached, limits that have
+// refilled are dropped, as they are no different from new ones, followed by the
+// least recently used limits. A value of zero or less removes the cap.
+func RateLimitMaxBuckets(n int) RateLimitOptFn {
+	return func(o rateLimitOpt) rateLimitOpt {
+		o.maxBuckets = n
+		return o
+	}
+}
+
+// RateLimitOnWait sets a callback that is called whenever a request has to wait
+// on the limiter, with the key of the limit that caused the longest wait.
+func RateLimitOnWait(fn func(key string, wait time.Duration)) RateLimitOptFn {
+	return func(o rateLimitOpt) rateLimitOpt {
+		o.onWait = fn
+		return o
+	}
+}
+
+// RateLimitStats are the wait time metrics of a RateLimiter.
+type RateLimitStats struct {
+	Requests  int64
+	Waits     int64
+	TotalWait time.Duration
+	MaxWait   time.Duration
+}
+
+// RateLimiter limits the rate of requests made by a client with token buckets,
+// globally, per host and per route. Every attempt of a request waits on the
+// limiter before it is sent, respecting the cancellation of the request's
+// context. A RateLimiter is safe for concurrent use.
+type RateLimiter struct {
+	opt rateLimitOpt
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	uses    uint64
+	stats   RateLimitStats
+}
+
+// NewRateLimiter creates a RateLimiter.
+func NewRateLimiter(opts ...RateLimitOptFn) *RateLimiter {
+	opt := rateLimitOpt{maxBuckets: 1024}
+	for _, o := range opts {
+		opt = o(opt)
+	}
+
+	return &RateLimiter{
+		opt:     opt,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Stats returns the wait time metrics of the limiter.
+func (l *RateLimiter) Stats() RateLimitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+// wait blocks until the request is allowed by all the limits it is subject to,
+// or the context is done.
+func (l *RateLimiter) wait(ctx context.Context, req *http.Request) error {
+	now := time.Now()
+
+	l.mu.Lock()
+	buckets := l.requestBuckets(req)
+	var (
+		wait    time.Duration
+		waitKey string
+	)
+	for key, b := range buckets {
+		if d := b.reserve(now); d > wait {
+			wait, waitKey = d, key
+		}
+	}
+	l.stats.Requests++
+	if wait > 0 {
+		l.stats.Waits++
+		l.stats.TotalWait += wait
+		if wait > l.stats.MaxWait {
+			l.stats.MaxWait = wait
+		}
+	}
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	if fn := l.opt.onWait; fn != nil {
+		fn(waitKey, wait)
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		l.mu.Lock()
+		for _, b := range buckets {
+			b.cancel()
+		}
+		l.mu.Unlock()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// allow takes a token from each of the limits the request is subject to, only
+// when all of them have a token available without waiting.
+func (l *RateLimiter) allow(req *http.Request) bool {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	buckets := l.requestBuckets(req)
+	for _, b := range buckets {
+		b.refill(now)
+		if b.tokens < 1 {
+			return false
+		}
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	l.stats.Requests++
+	return true
+}
+
+// release returns the tokens taken by allow for a request that was not sent.
+func (l *RateLimiter) release(req *http.Request) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, b := range l.requestBuckets(req) {
+		b.cancel()
+	}
+	l.stats.Requests--
+}
+
+// observe adapts the rate of the request's limits to the response.
+func (l *RateLimiter) observe(req *http.Request, resp *http.Response) {
+	if l.opt.decrease <= 0 || resp == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, b := range l.requestBuckets(req) {
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			b.setRate(math.Max(b.rate*l.opt.decrease, b.baseRate*0.01))
+		case resp.StatusCode < http.StatusBadRequest:
+			b.setRate(math.Min(b.rate+b.baseRate*l.opt.increase, b.baseRate))
+		}
+	}
+}
+
+func (l *RateLimiter) requestBuckets(req *http.Request) map[string]*tokenBucket {
+	limits := make(map[string]rateLimit, 3)
+	if l.opt.global.rate > 0 {
+		limits["global"] = l.opt.global
+	}
+	if l.opt.host.rate > 0 {
+		limits["host:"+req.URL.Host] = l.opt.host
+	}
+	if l.opt.route.rate > 0 && l.opt.keyFn != nil {
+		limits["route:"+l.opt.keyFn(req)] = l.opt.route
+	}
+
+	if l.opt.maxBuckets > 0 {
+		var missing int
+		for key := range limits {
+			if _, ok := l.buckets[key]; !ok {
+				missing++
+			}
+		}
+		if missing > 0 && len(l.buckets)+missing > l.opt.maxBuckets {
+			l.evict(time.Now(), limits, missing)
+		}
+	}
+
+	buckets := make(map[string]*tokenBucket, len(limits))
+	for key, limit := range limits {
+		buckets[key] = l.bucket(key, limit)
+	}
+	return buckets
+}
+
+func (l *RateLimiter) bucket(key string, limit rateLimit) *tokenBucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		burst := float64(limit.burst)
+		if burst < 1 {
+			burst = 1
+		}
+		b = &tokenBucket{
+			rate:     limit.rate,
+			baseRate: limit.rate,
+			burst:    burst,
+			tokens:   burst,
+		}
+		l.buckets[key] = b
+	}
+	l.uses++
+	b.used = l.uses
+	return b
+}
+
+// evict drops the buckets that have refilled at their configured rate, as they
+// are no different from new ones, then the least recently used buckets until
+// there is room for n new buckets. The buckets of the limits the current
+// request is subject to are kept.
+func (l *RateLimiter) evict(now time.Time, keep map[string]rateLimit, n int) {
+	for key, b := range l.buckets {
+		if _, ok := keep[key]; ok {
+			continue
+		}
+		b.refill(now)
+		if b.tokens >= b.burst && b.rate == b.baseRate {
+			delete(l.buckets, key)
+		}
+	}
+
+	for len(l.buckets)+n > l.opt.maxBuckets {
+		var (
+			oldestKey string
+			oldest    *tokenBucket
+		)
+		for key, b := range l.buckets {
+			if _, ok := keep[key]; ok {
+				continue
+			}
+			if oldest == nil || b.used < oldest.used {
+				oldestKey, oldest = key, b
+			}
+		}
+		if oldest == nil {
+			return
+		}
+		delete(l.buckets, oldestKey)
+	}
+}
+
+// tokenBucket is not safe for concurrent use, access is guarded by the RateLimiter.
+type tokenBucket struct {
+	rate, baseRate float64
+	burst          float64
+	tokens         float64
+	last           time.Time
+	used           uint64
+}
+
+// reserve takes a token from the bucket, returning how long the caller must
+// wait before the token is available.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.refill(now)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns a reserved token to the bucket.
+func (b *tokenBucket) cancel() {
+	b.tokens = math.Min(b.tokens+1, b.burst)
+}
+
+func (b *tokenBucket) setRate(rate float64) {
+	b.refill(time.Now())
+	b.rate = rate
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.last.IsZero() && now.After(b.last) {
+		b.tokens = math.Min(b.tokens+now.Sub(b.last).Seconds()*b.rate, b.burst)
+	}
+	if now.After(b.last) {
+		b.last = now
+	}
+}
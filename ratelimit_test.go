@@ -0,0 +1,131 @@
+package httpc_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jsteenb2/httpc"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("global limit waits", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusOK)
+
+		var waits []string
+		limiter := httpc.NewRateLimiter(
+			httpc.RateLimitGlobal(100, 1),
+			httpc.RateLimitOnWait(func(key string, wait time.Duration) {
+				waits = append(waits, key)
+			}),
+		)
+		client := httpc.New(doer, httpc.WithRateLimiter(limiter))
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			err := client.
+				Get("http://example.com/foo").
+				Success(httpc.StatusOK()).
+				Do(context.TODO())
+			mustNoError(t, err)
+		}
+
+		if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+			t.Errorf("expected requests to be limited: took %s", elapsed)
+		}
+
+		stats := limiter.Stats()
+		equals(t, int64(3), stats.Requests)
+		equals(t, int64(2), stats.Waits)
+		mustEquals(t, 2, len(waits))
+		equals(t, "global", waits[0])
+	})
+
+	t.Run("per host limits are independent", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusOK)
+
+		limiter := httpc.NewRateLimiter(httpc.RateLimitPerHost(1, 1))
+		client := httpc.New(doer, httpc.WithRateLimiter(limiter))
+
+		for _, host := range []string{"a.example.com", "b.example.com", "c.example.com"} {
+			err := client.
+				Get("http://" + host + "/foo").
+				Success(httpc.StatusOK()).
+				Do(context.TODO())
+			mustNoError(t, err)
+		}
+
+		equals(t, int64(0), limiter.Stats().Waits)
+	})
+
+	t.Run("wait respects context cancellation", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusOK)
+
+		limiter := httpc.NewRateLimiter(httpc.RateLimitGlobal(0.1, 1))
+		client := httpc.New(doer, httpc.WithRateLimiter(limiter))
+
+		mustNoError(t, client.Get("/foo").Success(httpc.StatusOK()).Do(context.TODO()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err := client.Get("/foo").Success(httpc.StatusOK()).Do(ctx)
+		mustError(t, err)
+
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected wait to be cancelled: took %s", elapsed)
+		}
+		equals(t, 1, doer.doCallCount)
+	})
+
+	t.Run("adaptive lowers rate on too many requests", func(t *testing.T) {
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			if doer.doCallCount == 1 {
+				return stubResp(http.StatusTooManyRequests), nil
+			}
+			return stubResp(http.StatusOK), nil
+		}
+
+		limiter := httpc.NewRateLimiter(
+			httpc.RateLimitGlobal(1000, 1),
+			httpc.RateLimitAdaptive(0.02, 0.1),
+		)
+		client := httpc.New(doer, httpc.WithRateLimiter(limiter))
+
+		mustError(t, client.Get("/foo").Success(httpc.StatusOK()).Do(context.TODO()))
+		mustNoError(t, client.Get("/foo").Success(httpc.StatusOK()).Do(context.TODO()))
+
+		if max := limiter.Stats().MaxWait; max < 20*time.Millisecond {
+			t.Errorf("expected lowered rate to increase wait: got %s", max)
+		}
+	})
+
+	t.Run("least recently used limit is dropped at the cap", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusOK)
+
+		limiter := httpc.NewRateLimiter(
+			httpc.RateLimitPerHost(0.1, 1),
+			httpc.RateLimitMaxBuckets(2),
+		)
+		client := httpc.New(doer, httpc.WithRateLimiter(limiter))
+
+		for _, host := range []string{"a.example.com", "b.example.com", "c.example.com", "a.example.com"} {
+			err := client.
+				Get("http://" + host + "/foo").
+				Success(httpc.StatusOK()).
+				Do(context.TODO())
+			mustNoError(t, err)
+		}
+		equals(t, int64(0), limiter.Stats().Waits)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := client.Get("http://c.example.com/foo").Success(httpc.StatusOK()).Do(ctx)
+		mustError(t, err)
+		equals(t, int64(1), limiter.Stats().Waits)
+	})
+}
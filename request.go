@@ -8,10 +8,11 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 )
 
 // ErrInvalidEncodeFn is an error that is returned when calling the Request Do and the
-// encode function is not set.
+// encoder is not set.
 var ErrInvalidEncodeFn = errors.New("no encode fn provided for body")
 
 // ResponseErrorFn is a response error function that can be used to provide
@@ -27,15 +28,23 @@ type kvPair struct {
 type Request struct {
 	Method, Addr string
 	doer         Doer
+	middleware   []Middleware
 	body         interface{}
+	bodyReader   io.Reader
+	bodySize     int64
+	bodyRewind   func() (io.Reader, error)
 
 	headers []kvPair
 	params  []kvPair
 
-	authFn        AuthFn
-	encodeFn      EncodeFn
+	auth          Authorizer
+	encoder       Encoder
 	decodeFn      DecodeFn
+	decodeContent interface{}
+	codecs        Codecs
 	onErrorFn     DecodeFn
+	streamFn      StreamFn
+	progressFn    ProgressFn
 	responseErrFn ResponseErrorFn
 
 	notFoundFns    []StatusFn
@@ -44,12 +53,33 @@ type Request struct {
 	successFns     []StatusFn
 
 	backoff BackoffOptFn
+	breaker *CircuitBreaker
+	budget  *RetryBudget
+	limiter *RateLimiter
+	hedger  *Hedger
+	clock   Clock
+
+	onRetry  []RetryNotifyFn
+	onGiveUp []GiveUpFn
+
+	maxRetryWait   time.Duration
+	attemptTimeout time.Duration
+	timeout        time.Duration
+	spillThreshold int64
 }
 
-// Auth sets the authorization for hte request, overriding the authFn set
+// AttemptTimeout sets the timeout of each attempt of the Request, overriding
+// the attempt timeout set by the client. An attempt that times out is retried
+// as directed by the backoff.
+func (r *Request) AttemptTimeout(d time.Duration) *Request {
+	r.attemptTimeout = d
+	return r
+}
+
+// Auth sets the authorization for hte request, overriding the Authorizer set
 // by the client.
-func (r *Request) Auth(authFn AuthFn) *Request {
-	r.authFn = authFn
+func (r *Request) Auth(auth Authorizer) *Request {
+	r.auth = auth
 	return r
 }
 
@@ -59,9 +89,32 @@ func (r *Request) Backoff(b BackoffOptFn) *Request {
 	return r
 }
 
-// Body sets the body of the Request.
+// Body sets the body of the Request, which is encoded by the encoder. A
+// *Multipart body bypasses the encoder and is streamed.
 func (r *Request) Body(v interface{}) *Request {
 	r.body = v
+	r.bodyReader = nil
+	return r
+}
+
+// BodyReader sets a raw body of the Request that is streamed from the reader,
+// bypassing the encoder. When the size is known, it is sent as the
+// Content-Length, otherwise provide a negative size and the body is sent with
+// chunked transfer encoding. As the reader can only be read once, the request
+// is not retried unless a rewind func is set with BodyRewind.
+func (r *Request) BodyReader(rdr io.Reader, size int64) *Request {
+	r.bodyReader = rdr
+	r.bodySize = size
+	r.body = nil
+	return r
+}
+
+// BodyRewind sets the func that provides the body set by BodyReader anew, i.e.
+// by seeking a file to its start, allowing the request to be retried. The func
+// is also called by the request's GetBody, i.e. when an Authorizer signs a
+// digest of the body, after which the body is rewound again before it is sent.
+func (r *Request) BodyRewind(fn func() (io.Reader, error)) *Request {
+	r.bodyRewind = fn
 	return r
 }
 
@@ -77,6 +130,15 @@ func (r *Request) Decode(fn DecodeFn) *Request {
 	return r
 }
 
+// DecodeContent decodes the response body into v with the decoder of the client's
+// codecs that matches the response's Content-Type. A response with a media type
+// that has no decoder fails with an ErrUnexpectedContentType, rather than the
+// error of decoding it with the wrong decoder.
+func (r *Request) DecodeContent(v interface{}) *Request {
+	r.decodeContent = v
+	return r
+}
+
 // DecodeJSON is a shorthand for decoding JSON response body.
 func (r *Request) DecodeJSON(v interface{}) *Request {
 	return r.Decode(JSONDecode(v))
@@ -88,6 +150,14 @@ func (r *Request) Exists(fn StatusFn) *Request {
 	return r
 }
 
+// Hedge sets the hedger of the Request, overriding the hedger set by the client.
+// Only requests with an idempotent method, as configured on the hedger, are
+// hedged.
+func (r *Request) Hedge(h *Hedger) *Request {
+	r.hedger = h
+	return r
+}
+
 // Header adds a header to the request.
 func (r *Request) Header(key, value string) *Request {
 	r.headers = append(r.headers, kvPair{key: key, value: value})
@@ -111,6 +181,13 @@ func (r *Request) Headers(key, value string, pairs ...string) *Request {
 	return hReq
 }
 
+// MaxRetryWait caps the wait between attempts, including server directed
+// delays from a Retry-After header, overriding the max wait set by the client.
+func (r *Request) MaxRetryWait(d time.Duration) *Request {
+	r.maxRetryWait = d
+	return r
+}
+
 // OnError provides a decode hook to decode a responses body. Applied
 // when the response's status code does not match the expected.
 func (r *Request) OnError(fn DecodeFn) *Request {
@@ -124,6 +201,28 @@ func (r *Request) NotFound(fn StatusFn) *Request {
 	return r
 }
 
+// OnGiveUp appends a hook that is called when a retriable error is no longer
+// retried, i.e. the backoff or retry budget is exhausted. Hooks set by the
+// client are called first.
+func (r *Request) OnGiveUp(fn GiveUpFn) *Request {
+	r.onGiveUp = append(r.onGiveUp, fn)
+	return r
+}
+
+// OnProgress sets a callback that is called as the body of a successful
+// response is read, by the decoder or the stream func.
+func (r *Request) OnProgress(fn ProgressFn) *Request {
+	r.progressFn = fn
+	return r
+}
+
+// OnRetry appends a hook that is called before each retry of the request. Hooks
+// set by the client are called first.
+func (r *Request) OnRetry(fn RetryNotifyFn) *Request {
+	r.onRetry = append(r.onRetry, fn)
+	return r
+}
+
 // QueryParam allows a user to set query params on their request. This can be
 // called numerous times. Will add keys for each value that is passed in here.
 // In the case of duplicate query param values, the last pair that is entered
@@ -155,59 +254,200 @@ func (r *Request) Retry(fn RetryFn) *Request {
 	return fn(r)
 }
 
+// Stream sets the func that consumes the body of a successful response in place
+// of the decoder, i.e. to pipe a download to disk without buffering it. The
+// body is closed when the func returns, reading up to 64KiB of any unread body
+// so the connection can be reused. An error returned by the func is retried
+// only if it provides a Retry method that returns true.
+func (r *Request) Stream(fn StreamFn) *Request {
+	r.streamFn = fn
+	return r
+}
+
 // Success appends a success func to the Request.
 func (r *Request) Success(fn StatusFn) *Request {
 	r.successFns = append(r.successFns, fn)
 	return r
 }
 
+// Timeout sets the deadline of the Request, including all attempts and the
+// waits between them, overriding the timeout set by the client.
+func (r *Request) Timeout(d time.Duration) *Request {
+	r.timeout = d
+	return r
+}
+
+// Use appends middleware to the Request. Request middleware is wrapped by the
+// middleware set on the client, with the first middleware provided being the
+// outermost of the request's middleware.
+func (r *Request) Use(mw ...Middleware) *Request {
+	r.middleware = append(r.middleware, mw...)
+	return r
+}
+
 // Do makes the http request and applies the backoff.
 func (r *Request) Do(ctx context.Context) error {
-	return retry(ctx, r.do, r.backoff)
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	body, err := r.requestBody()
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		defer body.close()
+	}
+
+	attempt := func(ctx context.Context) error {
+		return r.attempt(ctx, body)
+	}
+	return retry(ctx, attempt, retryOpts{
+		backoff: r.backoff,
+		budget:  r.budget,
+		clock:   r.clock,
+		maxWait: r.maxRetryWait,
+
+		onRetry:  r.onRetry,
+		onGiveUp: r.onGiveUp,
+	})
 }
 
-func (r *Request) do(ctx context.Context) error {
-	var body io.Reader
-	if r.body != nil {
-		if r.encodeFn == nil {
-			return ErrInvalidEncodeFn
-		}
+// attempt makes a single attempt of the request, bounded by the attempt timeout.
+// An attempt that fails because it timed out is retriable.
+// A request with a body that can not be replayed is never retried.
+func (r *Request) attempt(ctx context.Context, body requestBody) error {
+	err := r.timedAttempt(ctx, body)
+	if err == nil || body == nil || body.replayable() {
+		return err
+	}
 
-		encodedBody, err := r.encodeFn(r.body)
-		if err != nil {
-			return NewClientErr(Err(err))
-		}
-		body = encodedBody
+	var httpErr *HTTPErr
+	if errors.As(err, &httpErr) {
+		httpErr.retry = false
+		return err
+	}
+	return NewClientErr(Err(err))
+}
+
+func (r *Request) timedAttempt(ctx context.Context, body requestBody) error {
+	if r.attemptTimeout <= 0 {
+		return r.do(ctx, body)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, r.attemptTimeout)
+	defer cancel()
+
+	err := r.do(attemptCtx, body)
+	if err == nil || ctx.Err() != nil || attemptCtx.Err() != context.DeadlineExceeded {
+		return err
 	}
 
-	req, err := http.NewRequest(r.Method, r.Addr, body)
+	var httpErr *HTTPErr
+	if errors.As(err, &httpErr) {
+		httpErr.retry = true
+		return err
+	}
+	return NewClientErr(Err(err), Retry())
+}
+
+// requestBody creates the body sent by the attempts of the request, encoding
+// the body once so that it can be replayed.
+func (r *Request) requestBody() (requestBody, error) {
+	if r.bodyReader != nil {
+		return &streamBody{
+			r:      r.bodyReader,
+			size:   r.bodySize,
+			rewind: r.bodyRewind,
+		}, nil
+	}
+	if r.body == nil {
+		return nil, nil
+	}
+	if m, ok := r.body.(*Multipart); ok {
+		return &multipartBody{m: m}, nil
+	}
+
+	if r.encoder == nil {
+		return nil, ErrInvalidEncodeFn
+	}
+	encodedBody, err := r.encoder.Encode(r.body)
+	if err != nil {
+		return nil, NewClientErr(Err(err))
+	}
+	body, err := newReplayBody(encodedBody, r.spillThreshold, r.encoder.ContentType())
+	if err != nil {
+		return nil, NewClientErr(Err(err))
+	}
+	return body, nil
+}
+
+func (r *Request) do(ctx context.Context, body requestBody) error {
+	req, err := http.NewRequest(r.Method, r.Addr, nil)
 	if err != nil {
 		return NewClientErr(Err(err))
 	}
 	req = req.WithContext(ctx)
+	if body != nil {
+		if err := body.setOn(req); err != nil {
+			return NewClientErr(Err(err), Req(req))
+		}
+	}
 
 	if len(r.headers) > 0 {
 		for _, pair := range r.headers {
 			req.Header.Set(pair.key, pair.value)
 		}
 	}
+	if body != nil {
+		if cType := body.contentType(); cType != "" && (req.Header.Get("Content-Type") == "" || isMultipart(body)) {
+			req.Header.Set("Content-Type", cType)
+		}
+	}
+	if a, ok := r.encoder.(Accepter); ok && a.Accept() != "" && req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", a.Accept())
+	}
 
 	if len(r.params) > 0 {
 		params := req.URL.Query()
 		for _, kv := range r.params {
-			params.Add(kv.key, kv.value)
+			params.Set(kv.key, kv.value)
 		}
 		req.URL.RawQuery = params.Encode()
 	}
 
-	if r.authFn != nil {
-		req = r.authFn(req)
+	if r.limiter != nil {
+		if err := r.limiter.wait(ctx, req); err != nil {
+			return NewClientErr(Err(err), Req(req))
+		}
+	}
+
+	if r.auth != nil {
+		authReq, err := r.auth.Authorize(ctx, req)
+		if err != nil {
+			opts := []ErrOptFn{Err(err), Req(req)}
+			if isRetryErr(err) {
+				opts = append(opts, Retry())
+			}
+			return NewClientErr(opts...)
+		}
+		req = authReq
+	}
+
+	var breakerDone func(*http.Response, error)
+	if r.breaker != nil {
+		done, err := r.breaker.allow(req)
+		if err != nil {
+			return NewClientErr(Err(err), Req(req), CircuitOpen())
+		}
+		breakerDone = done
 	}
 
-	resp, err := r.doer.Do(req)
+	resp, err := r.send(req, body, r.attemptDone(req, breakerDone))
 	if err != nil {
 		return r.responseErr(resp, err)
-		return NewClientErr(Err(err), Resp(resp))
 	}
 	defer func() {
 		drain(resp.Body)
@@ -215,7 +455,7 @@ func (r *Request) do(ctx context.Context) error {
 
 	status := resp.StatusCode
 	if !statusMatches(status, r.successFns) {
-		opts := append([]ErrOptFn{Resp(resp)}, r.statusErrOpts(status)...)
+		opts := append([]ErrOptFn{Resp(resp), errNow(r.clock.Now)}, r.statusErrOpts(status)...)
 		if r.onErrorFn != nil {
 			var buf bytes.Buffer
 			tee := io.TeeReader(resp.Body, &buf)
@@ -227,11 +467,31 @@ func (r *Request) do(ctx context.Context) error {
 		return NewClientErr(opts...)
 	}
 
-	if r.decodeFn == nil {
+	if r.progressFn != nil {
+		resp.Body = &progressReader{
+			ReadCloser: resp.Body,
+			total:      resp.ContentLength,
+			fn:         r.progressFn,
+		}
+	}
+
+	if r.streamFn != nil {
+		return r.stream(resp)
+	}
+
+	decodeFn := r.decodeFn
+	if r.decodeContent != nil {
+		fn, err := r.codecs.decoder(resp.Header.Get("Content-Type"), r.decodeContent)
+		if err != nil {
+			return NewClientErr(Err(err), Resp(resp))
+		}
+		decodeFn = fn
+	}
+	if decodeFn == nil {
 		return nil
 	}
 
-	if err := r.decodeFn(resp.Body); err != nil {
+	if err := decodeFn(resp.Body); err != nil {
 		opts := []ErrOptFn{Err(err), Resp(resp)}
 		if isRetryErr(err) {
 			opts = append(opts, Retry())
@@ -242,6 +502,75 @@ func (r *Request) do(ctx context.Context) error {
 	return nil
 }
 
+// send sends the request through the middleware chain, hedging it when the
+// request's method is hedged and its body can be read by two attempts at once.
+// The outcome of each attempt is recorded with done.
+func (r *Request) send(req *http.Request, body requestBody, done func(*http.Response, error)) (*http.Response, error) {
+	doer := r.chain()
+	hedge := r.hedger != nil && r.hedger.applies(req) && (body == nil || body.concurrentReplayable())
+	if !hedge {
+		resp, err := doer.Do(req)
+		done(resp, err)
+		return resp, err
+	}
+	return r.hedger.do(doer, req, hedgeHooks{
+		done:  done,
+		admit: r.admitHedge,
+		succeeded: func(resp *http.Response) bool {
+			return statusMatches(resp.StatusCode, r.successFns)
+		},
+	})
+}
+
+// admitHedge admits a hedged attempt when the rate limiter has capacity for it
+// without waiting and the circuit breaker allows it.
+func (r *Request) admitHedge(req *http.Request) (func(*http.Response, error), bool) {
+	if r.limiter != nil && !r.limiter.allow(req) {
+		return nil, false
+	}
+
+	var breakerDone func(*http.Response, error)
+	if r.breaker != nil {
+		done, err := r.breaker.allow(req)
+		if err != nil {
+			if r.limiter != nil {
+				r.limiter.release(req)
+			}
+			return nil, false
+		}
+		breakerDone = done
+	}
+	return r.attemptDone(req, breakerDone), true
+}
+
+// attemptDone returns the func that records the outcome of an attempt with the
+// circuit breaker and the rate limiter.
+func (r *Request) attemptDone(req *http.Request, breakerDone func(*http.Response, error)) func(*http.Response, error) {
+	return func(resp *http.Response, err error) {
+		if breakerDone != nil {
+			breakerDone(resp, err)
+		}
+		if r.limiter != nil {
+			r.limiter.observe(req, resp)
+		}
+	}
+}
+
+// isMultipart reports whether the body is multipart, which is always sent with
+// its own Content-Type as the boundary is required to parse it.
+func isMultipart(body requestBody) bool {
+	_, ok := body.(*multipartBody)
+	return ok
+}
+
+func (r *Request) chain() Doer {
+	doer := r.doer
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		doer = r.middleware[i](doer)
+	}
+	return doer
+}
+
 func (r *Request) statusErrOpts(status int) []ErrOptFn {
 	var opts []ErrOptFn
 	if statusMatches(status, r.retryStatusFns) {
@@ -0,0 +1,349 @@
+package httpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm        = "AWS4-HMAC-SHA256"
+	sigV4UnsignedPayload  = "UNSIGNED-PAYLOAD"
+	sigV4StreamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	sigV4TimeFormat       = "20060102T150405Z"
+	sigV4DateFormat       = "20060102"
+
+	// sha256 of an empty payload, also used for the empty chunk headers.
+	sigV4EmptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+type sigV4Opt struct {
+	sessionToken  string
+	unsigned      bool
+	chunkSize     int
+	contentHeader bool
+	now           func() time.Time
+}
+
+// SigV4OptFn is a optional parameter for the SigV4Auth Authorizer.
+type SigV4OptFn func(o sigV4Opt) sigV4Opt
+
+// SigV4SessionToken sets the session token of temporary credentials.
+func SigV4SessionToken(token string) SigV4OptFn {
+	return func(o sigV4Opt) sigV4Opt {
+		o.sessionToken = token
+		return o
+	}
+}
+
+// SigV4UnsignedPayload signs the request without hashing the body.
+func SigV4UnsignedPayload() SigV4OptFn {
+	return func(o sigV4Opt) sigV4Opt {
+		o.unsigned = true
+		return o
+	}
+}
+
+// SigV4Streaming signs the body in chunks of chunkSize bytes as it is sent,
+// using the aws-chunked content encoding. The request's content length must be
+// known. The chunk size must be at least 8KB.
+func SigV4Streaming(chunkSize int) SigV4OptFn {
+	return func(o sigV4Opt) sigV4Opt {
+		o.chunkSize = chunkSize
+		return o
+	}
+}
+
+// SigV4ContentHeader sets the X-Amz-Content-Sha256 header on every request.
+// The header is always set for the s3 service, and when the payload is
+// unsigned or streamed.
+func SigV4ContentHeader() SigV4OptFn {
+	return func(o sigV4Opt) sigV4Opt {
+		o.contentHeader = true
+		return o
+	}
+}
+
+// SigV4Now sets the func used to obtain the signing time. Defaults to time.Now.
+func SigV4Now(now func() time.Time) SigV4OptFn {
+	return func(o sigV4Opt) sigV4Opt {
+		o.now = now
+		return o
+	}
+}
+
+// SigV4Auth signs requests with AWS Signature Version 4. By default the body
+// produced by the request's EncodeFn is hashed into the signature. The request
+// is signed for every attempt, so retries are sent with a fresh timestamp.
+func SigV4Auth(accessKey, secretKey, region, service string, opts ...SigV4OptFn) Authorizer {
+	opt := sigV4Opt{now: time.Now}
+	for _, o := range opts {
+		opt = o(opt)
+	}
+
+	s := &sigV4Signer{
+		accessKey: accessKey,
+		secretKey: secretKey,
+		region:    region,
+		service:   service,
+		opt:       opt,
+	}
+	return AuthorizerFunc(s.sign)
+}
+
+type sigV4Signer struct {
+	accessKey, secretKey string
+	region, service      string
+	opt                  sigV4Opt
+}
+
+func (s *sigV4Signer) sign(ctx context.Context, r *http.Request) (*http.Request, error) {
+	t := s.opt.now().UTC()
+	amzDate := t.Format(sigV4TimeFormat)
+	scope := strings.Join([]string{t.Format(sigV4DateFormat), s.region, s.service, "aws4_request"}, "/")
+
+	r.Header.Set("X-Amz-Date", amzDate)
+	if s.opt.sessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", s.opt.sessionToken)
+	}
+
+	var payloadHash string
+	switch {
+	case s.opt.chunkSize > 0:
+		if r.Body == nil || r.Body == http.NoBody {
+			r.Body = http.NoBody
+			r.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+		} else if r.ContentLength <= 0 {
+			return nil, errors.New("sigv4: streaming requires a known content length")
+		}
+		payloadHash = sigV4StreamingPayload
+		r.Header.Set("Content-Encoding", "aws-chunked")
+		r.Header.Set("X-Amz-Decoded-Content-Length", strconv.FormatInt(r.ContentLength, 10))
+		r.ContentLength = sigV4ChunkedLength(r.ContentLength, int64(s.opt.chunkSize))
+	case s.opt.unsigned:
+		payloadHash = sigV4UnsignedPayload
+	default:
+		sum, err := bodyDigest(r, sha256.New)
+		if errors.Is(err, ErrBodyNotReplayable) {
+			return nil, fmt.Errorf("sigv4: %w, use SigV4UnsignedPayload or SigV4Streaming", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		payloadHash = hex.EncodeToString(sum)
+	}
+	if s.opt.contentHeader || s.opt.unsigned || s.opt.chunkSize > 0 || s.service == "s3" {
+		r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	canonicalReq, signedHeaders := s.canonicalRequest(r, payloadHash)
+	strToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, hexSHA256([]byte(canonicalReq))}, "\n")
+
+	key := s.signingKey(t)
+	signature := hex.EncodeToString(hmacSHA256(key, []byte(strToSign)))
+
+	r.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s.accessKey, scope, signedHeaders, signature,
+	))
+
+	if s.opt.chunkSize > 0 {
+		newChunker := func(body io.ReadCloser) io.ReadCloser {
+			return &sigV4ChunkReader{
+				body:      body,
+				chunkSize: s.opt.chunkSize,
+				prevSig:   signature,
+				signChunk: func(prevSig string, chunk []byte) string {
+					chunkStrToSign := strings.Join([]string{
+						sigV4Algorithm + "-PAYLOAD", amzDate, scope, prevSig, sigV4EmptySHA256, hexSHA256(chunk),
+					}, "\n")
+					return hex.EncodeToString(hmacSHA256(key, []byte(chunkStrToSign)))
+				},
+			}
+		}
+		r.Body = newChunker(r.Body)
+		if getBody := r.GetBody; getBody != nil {
+			r.GetBody = func() (io.ReadCloser, error) {
+				body, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return newChunker(body), nil
+			}
+		}
+	}
+
+	return r, nil
+}
+
+func (s *sigV4Signer) canonicalRequest(r *http.Request, payloadHash string) (string, string) {
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+	if r.ContentLength > 0 {
+		headers["content-length"] = strconv.FormatInt(r.ContentLength, 10)
+	}
+	for k, vals := range r.Header {
+		lk := strings.ToLower(k)
+		if !strings.HasPrefix(lk, "x-amz-") && lk != "content-type" && lk != "content-md5" && lk != "content-encoding" {
+			continue
+		}
+		trimmed := make([]string, 0, len(vals))
+		for _, v := range vals {
+			trimmed = append(trimmed, strings.Join(strings.Fields(v), " "))
+		}
+		headers[lk] = strings.Join(trimmed, ",")
+	}
+
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range keys {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(keys, ";")
+
+	path := r.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	uri := sigV4Escape(path, false)
+	r.URL.RawPath = uri
+	if s.service != "s3" {
+		uri = sigV4Escape(uri, false)
+	}
+
+	query := r.URL.Query()
+	qKeys := make([]string, 0, len(query))
+	for k := range query {
+		qKeys = append(qKeys, k)
+	}
+	sort.Strings(qKeys)
+	var params []string
+	for _, k := range qKeys {
+		vals := append([]string(nil), query[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			params = append(params, sigV4Escape(k, true)+"="+sigV4Escape(v, true))
+		}
+	}
+	r.URL.RawQuery = strings.Join(params, "&")
+
+	return strings.Join([]string{
+		r.Method,
+		uri,
+		r.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n"), signedHeaders
+}
+
+func (s *sigV4Signer) signingKey(t time.Time) []byte {
+	k := hmacSHA256([]byte("AWS4"+s.secretKey), []byte(t.Format(sigV4DateFormat)))
+	k = hmacSHA256(k, []byte(s.region))
+	k = hmacSHA256(k, []byte(s.service))
+	return hmacSHA256(k, []byte("aws4_request"))
+}
+
+// sigV4ChunkReader encodes the body into signed aws-chunked chunks, each chunk's
+// signature chaining off the signature of the previous chunk.
+type sigV4ChunkReader struct {
+	body      io.ReadCloser
+	chunkSize int
+	prevSig   string
+	signChunk func(prevSig string, chunk []byte) string
+
+	buf  bytes.Buffer
+	done bool
+}
+
+func (c *sigV4ChunkReader) Read(p []byte) (int, error) {
+	for c.buf.Len() == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+
+		chunk := make([]byte, c.chunkSize)
+		n, err := io.ReadFull(c.body, chunk)
+		switch err {
+		case nil, io.ErrUnexpectedEOF:
+			c.writeChunk(chunk[:n])
+		case io.EOF:
+			c.writeChunk(nil)
+			c.done = true
+		default:
+			return 0, err
+		}
+	}
+	return c.buf.Read(p)
+}
+
+func (c *sigV4ChunkReader) writeChunk(chunk []byte) {
+	c.prevSig = c.signChunk(c.prevSig, chunk)
+	fmt.Fprintf(&c.buf, "%x;chunk-signature=%s\r\n", len(chunk), c.prevSig)
+	c.buf.Write(chunk)
+	c.buf.WriteString("\r\n")
+}
+
+func (c *sigV4ChunkReader) Close() error {
+	return c.body.Close()
+}
+
+func sigV4ChunkedLength(contentLength, chunkSize int64) int64 {
+	chunkLen := func(n int64) int64 {
+		return int64(len(strconv.FormatInt(n, 16))) + int64(len(";chunk-signature=")) + 64 + 2 + n + 2
+	}
+
+	full := contentLength / chunkSize
+	total := full * chunkLen(chunkSize)
+	if rem := contentLength % chunkSize; rem > 0 {
+		total += chunkLen(rem)
+	}
+	return total + chunkLen(0)
+}
+
+func sigV4Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hexSHA256(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
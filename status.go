@@ -78,6 +78,14 @@ func StatusUnprocessableEntity() StatusFn {
 	}
 }
 
+// StatusTooManyRequests compares the response's status code to match Status Too Many Requests.
+// Use with RetryStatus to retry rate limited requests, honoring the Retry-After header.
+func StatusTooManyRequests() StatusFn {
+	return func(status int) bool {
+		return http.StatusTooManyRequests == status
+	}
+}
+
 // StatusInternalServerError compares the response's status code to match Status Internal Server Error.
 func StatusInternalServerError() StatusFn {
 	return func(status int) bool {
@@ -85,6 +93,13 @@ func StatusInternalServerError() StatusFn {
 	}
 }
 
+// StatusServiceUnavailable compares the response's status code to match Status Service Unavailable.
+func StatusServiceUnavailable() StatusFn {
+	return func(status int) bool {
+		return http.StatusServiceUnavailable == status
+	}
+}
+
 func statusMatches(status int, fns []StatusFn) bool {
 	for _, fn := range fns {
 		if fn(status) {
@@ -29,10 +29,18 @@ func TestStatusFuncs(t *testing.T) {
 				statusCode: http.StatusUnprocessableEntity,
 				statusFn:   httpc.StatusUnprocessableEntity(),
 			},
+			{
+				statusCode: http.StatusTooManyRequests,
+				statusFn:   httpc.StatusTooManyRequests(),
+			},
 			{
 				statusCode: http.StatusInternalServerError,
 				statusFn:   httpc.StatusInternalServerError(),
 			},
+			{
+				statusCode: http.StatusServiceUnavailable,
+				statusFn:   httpc.StatusServiceUnavailable(),
+			},
 		}
 
 		for _, tt := range tests {
@@ -0,0 +1,55 @@
+package httpc
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// streamDrainLimit is the most that is read from a streamed response body the
+// caller did not consume, so the connection can be reused, before it is closed.
+const streamDrainLimit = 64 << 10
+
+// StreamFn consumes the body of a successful response. The response body is
+// owned by the StreamFn until it returns, after which it is closed.
+type StreamFn func(resp *http.Response) error
+
+// ProgressFn is called as a response body is read, with the number of bytes
+// read so far and the total, which is -1 when the length of the body is
+// unknown.
+type ProgressFn func(read, total int64)
+
+// stream hands the response to the stream func, releasing the connection once
+// it returns.
+func (r *Request) stream(resp *http.Response) error {
+	body := resp.Body
+	defer func() {
+		io.CopyN(ioutil.Discard, body, streamDrainLimit)
+		body.Close()
+		resp.Body = http.NoBody
+	}()
+
+	if err := r.streamFn(resp); err != nil {
+		opts := []ErrOptFn{Err(err), Req(resp.Request)}
+		if isRetryErr(err) {
+			opts = append(opts, Retry())
+		}
+		return NewClientErr(opts...)
+	}
+	return nil
+}
+
+type progressReader struct {
+	io.ReadCloser
+	read, total int64
+	fn          ProgressFn
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.read += int64(n)
+		p.fn(p.read, p.total)
+	}
+	return n, err
+}
@@ -0,0 +1,124 @@
+package httpc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jsteenb2/httpc"
+)
+
+func TestStream(t *testing.T) {
+	t.Run("hands the body to the stream func", func(t *testing.T) {
+		body := &trackingBody{Reader: strings.NewReader("streamed payload")}
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: body, ContentLength: 16}, nil
+		}
+
+		client := httpc.New(doer)
+
+		var buf bytes.Buffer
+		var progress []int64
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			OnProgress(func(read, total int64) {
+				equals(t, int64(16), total)
+				progress = append(progress, read)
+			}).
+			Stream(func(resp *http.Response) error {
+				_, err := io.Copy(&buf, resp.Body)
+				return err
+			}).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, "streamed payload", buf.String())
+		mustEquals(t, true, len(progress) > 0)
+		equals(t, int64(16), progress[len(progress)-1])
+		equals(t, true, body.closed)
+	})
+
+	t.Run("closes without reading the whole body", func(t *testing.T) {
+		body := &trackingBody{Reader: bytes.NewReader(make([]byte, 1<<20))}
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: body}, nil
+		}
+
+		client := httpc.New(doer)
+
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Stream(func(resp *http.Response) error {
+				_, err := resp.Body.Read(make([]byte, 10))
+				return err
+			}).
+			Do(context.TODO())
+		mustNoError(t, err)
+
+		equals(t, true, body.closed)
+		equals(t, true, body.read < 1<<20)
+	})
+
+	t.Run("stream func error", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusOK)
+
+		client := httpc.New(doer)
+
+		streamErr := errors.New("disk full")
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Stream(func(resp *http.Response) error {
+				return streamErr
+			}).
+			Do(context.TODO())
+		mustError(t, err)
+
+		equals(t, true, errors.Is(err, streamErr))
+		equals(t, false, retryErr(err))
+	})
+
+	t.Run("not called for unexpected status", func(t *testing.T) {
+		doer := newHappyDoer(http.StatusInternalServerError)
+
+		client := httpc.New(doer)
+
+		var called bool
+		err := client.
+			Get("/foo").
+			Success(httpc.StatusOK()).
+			Stream(func(resp *http.Response) error {
+				called = true
+				return nil
+			}).
+			Do(context.TODO())
+		mustError(t, err)
+
+		equals(t, false, called)
+	})
+}
+
+type trackingBody struct {
+	io.Reader
+	read   int
+	closed bool
+}
+
+func (b *trackingBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	b.read += n
+	return n, err
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
@@ -0,0 +1,52 @@
+package httpc
+
+import (
+	"context"
+	"io"
+)
+
+// Do makes the http request and decodes the response body into a new value of
+// type T. The decode func constructs the DecodeFn for the target, i.e. JSONDecode
+// or GobDecode. The zero value of T is returned when the request fails.
+func Do[T any](ctx context.Context, r *Request, decode func(interface{}) DecodeFn) (T, error) {
+	var v T
+	r.Decode(func(rd io.Reader) error {
+		v = *new(T)
+		return decode(&v)(rd)
+	})
+
+	if err := r.Do(ctx); err != nil {
+		return *new(T), err
+	}
+	return v, nil
+}
+
+// DoWithErr makes the http request and decodes the response body into a new
+// value of type T. When the response's status code does not match the expected,
+// the response body is decoded into a value of type E with the same decode func
+// and returned alongside the error. Any OnError decoder set on the request is
+// replaced.
+func DoWithErr[T, E any](ctx context.Context, r *Request, decode func(interface{}) DecodeFn) (T, E, error) {
+	var errVal E
+	r.OnError(func(rd io.Reader) error {
+		errVal = *new(E)
+		return decode(&errVal)(rd)
+	})
+
+	v, err := Do[T](ctx, r, decode)
+	if err != nil {
+		return v, errVal, err
+	}
+	return v, *new(E), nil
+}
+
+// DoJSON is a shorthand for Do with a JSON decoder.
+func DoJSON[T any](ctx context.Context, r *Request) (T, error) {
+	return Do[T](ctx, r, JSONDecode)
+}
+
+// GetJSON makes a get http request expecting a status OK and decodes the JSON
+// response body into a new value of type T.
+func GetJSON[T any](ctx context.Context, c *Client, addr string) (T, error) {
+	return DoJSON[T](ctx, c.Get(addr).Success(StatusOK()))
+}
@@ -0,0 +1,75 @@
+package httpc_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/jsteenb2/httpc"
+)
+
+func TestDo(t *testing.T) {
+	t.Run("decodes typed response", func(t *testing.T) {
+		doer := newEchoDoer(t, http.StatusOK)
+
+		client := httpc.New(doer)
+
+		expected := foo{Name: "name", S: "string"}
+		actual, err := httpc.Do[foo](context.TODO(), client.Post("/foo").Body(expected).Success(httpc.StatusOK()), httpc.JSONDecode)
+		mustNoError(t, err)
+
+		expected.Method = http.MethodPost
+		equals(t, expected, actual)
+	})
+
+	t.Run("returns zero value on error", func(t *testing.T) {
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			return stubRespNBody(t, http.StatusInternalServerError, foo{Name: "name"}), nil
+		}
+
+		client := httpc.New(doer)
+
+		actual, err := httpc.DoJSON[foo](context.TODO(), client.Get("/foo").Success(httpc.StatusOK()))
+		mustError(t, err)
+
+		equals(t, foo{}, actual)
+	})
+
+	t.Run("decodes typed error body", func(t *testing.T) {
+		type errBody struct{ Code string }
+
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			return stubRespNBody(t, http.StatusUnprocessableEntity, errBody{Code: "invalid"}), nil
+		}
+
+		client := httpc.New(doer)
+
+		actual, errResp, err := httpc.DoWithErr[foo, errBody](
+			context.TODO(),
+			client.Delete("/foo").Success(httpc.StatusNoContent()),
+			httpc.JSONDecode,
+		)
+		mustError(t, err)
+
+		equals(t, foo{}, actual)
+		equals(t, "invalid", errResp.Code)
+	})
+
+	t.Run("GetJSON", func(t *testing.T) {
+		doer := new(fakeDoer)
+		doer.doFn = func(r *http.Request) (*http.Response, error) {
+			return stubRespNBody(t, http.StatusOK, []foo{{Name: "1", Method: r.Method}, {Name: "2", Method: r.Method}}), nil
+		}
+
+		client := httpc.New(doer)
+
+		actual, err := httpc.GetJSON[[]foo](context.TODO(), client, "/foo")
+		mustNoError(t, err)
+
+		mustEquals(t, 2, len(actual))
+		equals(t, foo{Name: "1", Method: http.MethodGet}, actual[0])
+		equals(t, foo{Name: "2", Method: http.MethodGet}, actual[1])
+	})
+}